@@ -0,0 +1,26 @@
+package config
+
+import (
+	"audit-tool-orchestrator/cmd/config/validate"
+	"audit-tool-orchestrator/cmd/config/view"
+	"github.com/spf13/cobra"
+)
+
+func NewCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "config has subcommands to validate and view the config file",
+		Long:  "",
+		// the config file is checked by the sub-commands instead of being applied to them
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(
+		validate.NewCmd(),
+		view.NewCmd(),
+	)
+
+	return configCmd
+}
@@ -0,0 +1,50 @@
+package validate
+
+// check the config file and each of its profiles
+
+import (
+	"audit-tool-orchestrator/pkg/config"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file and each of its profiles.",
+		Long: "Read the config file set with --config, or the default one, rejecting unknown settings, and check " +
+			"the values of its shared settings and of each profile merged on top of them the way the commands " +
+			"check their flags.",
+		RunE: run,
+	}
+
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	flags, err := config.GetConfigFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	path, err := config.ConfigFile(flags)
+	if err != nil {
+		return err
+	}
+
+	errs := config.ValidateFile(path)
+	if len(errs) > 0 {
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+
+		return fmt.Errorf("config file %s is not valid:\n%s", path, strings.Join(messages, "\n"))
+	}
+
+	log.Infof("Config file %s is valid.\n", path)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package view
+
+// print the settings applied to the commands
+
+import (
+	"audit-tool-orchestrator/pkg/config"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Print the settings applied to the commands.",
+		Long: "Print in YAML the settings of the config file applied to the commands: the shared settings with the " +
+			"profile selected with --profile, or the profile named in the file, merged on top of them.",
+		RunE: run,
+	}
+
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	flags, err := config.GetConfigFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	path, err := config.ConfigFile(flags)
+	if err != nil {
+		return err
+	}
+
+	file, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	settings, profile, err := file.Resolve(flags.Profile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("# config: %s\n", path)
+	if len(profile) > 0 {
+		fmt.Printf("# profile: %s\n", profile)
+	}
+
+	return settings.Print(os.Stdout)
+}
@@ -0,0 +1,102 @@
+package harness
+
+// run a local API server with simulated Hive controllers to exercise the orchestrator offline
+
+import (
+	"audit-tool-orchestrator/pkg/harness"
+	"audit-tool-orchestrator/pkg/release"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+var flags = harness.HarnessFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "harness",
+		Short: "Run a local API server with simulated Hive controllers to exercise the orchestrator offline.",
+		Long: "Start etcd and kube-apiserver locally with the Hive CRDs and the ClusterImageSets of --image-set " +
+			"installed, and simulated controllers " +
+			"which mark the ClusterPools ready, fulfil the ClusterClaims with a ClusterDeployment and its admin " +
+			"kubeconfig Secret pointing back at the local API server, and finish the Jobs. The kubeconfig and an " +
+			"empty registry pull secret are written to --dir so `orchestrate pool`, `claim`, `job` and `run` can " +
+			"be pointed at the harness. The etcd and kube-apiserver binaries are installed with setup-envtest. " +
+			"It runs until interrupted.",
+		PreRunE: validation,
+		RunE:    run,
+	}
+
+	cmd.Flags().StringVar(&flags.AssetsDir, "assets-dir", "",
+		"directory of the etcd and kube-apiserver binaries. If not set, KUBEBUILDER_ASSETS is used.")
+	cmd.Flags().StringVar(&flags.Dir, "dir", harness.DefaultDir(),
+		"directory where the kubeconfig and the empty registry pull secret of the harness are written.")
+	cmd.Flags().StringSliceVar(&flags.Namespaces, "namespace", harness.DefaultNamespaces,
+		"namespaces created at start, for the ClusterPools and ClusterClaims.")
+	cmd.Flags().StringSliceVar(&flags.ImageSets, "image-set", harness.DefaultImageSets,
+		"OpenShift releases of the ClusterImageSets created at start, which the ClusterPools are resolved to.")
+	cmd.Flags().DurationVar(&flags.Controller.Interval, "interval", harness.DefaultInterval,
+		"time between two reconciliations of the simulated controllers.")
+	cmd.Flags().StringVar(&flags.Controller.ClusterVersion, "cluster-version", harness.DefaultClusterVersion,
+		"OpenShift version reported by the ClusterDeployments of the claimed clusters whose ClusterImageSet "+
+			"has no version.")
+	cmd.Flags().DurationVar(&flags.Controller.JobDuration, "job-duration", harness.DefaultJobDuration,
+		"time the Jobs run before they are finished.")
+	cmd.Flags().StringVar(&flags.Controller.JobResult, "job-result", harness.JobResults[0],
+		fmt.Sprintf("condition set on the finished Jobs. [Options: %s]", strings.Join(harness.JobResults, ", ")))
+
+	return cmd
+}
+
+func validation(cmd *cobra.Command, args []string) error {
+	if !harness.IsValidJobResult(flags.Controller.JobResult) {
+		return fmt.Errorf("invalid value for the flag --job-result (%s). The valid options are %s",
+			flags.Controller.JobResult, strings.Join(harness.JobResults, ", "))
+	}
+
+	for _, version := range flags.ImageSets {
+		if !release.IsOpenShiftRelease(version) {
+			return fmt.Errorf("invalid value for the flag --image-set (%s). It must be a release such as 4.10.3",
+				version)
+		}
+	}
+
+	if flags.Controller.Interval <= 0 || flags.Controller.JobDuration < 0 {
+		return fmt.Errorf("the flag --interval must be positive and --job-duration cannot be negative")
+	}
+
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	h, err := harness.Start(ctx, flags)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := h.Stop(); err != nil {
+			log.Errorf("Unable to stop the harness: %v\n", err)
+		}
+	}()
+
+	if err := h.WriteFiles(flags.Dir); err != nil {
+		return err
+	}
+
+	log.Infof("Harness started. Point the orchestrator at it with:\n"+
+		"export OPENSHIFT_KUBECONFIG=%s\nexport REGISTRY_PULL_SECRET=%s\n",
+		harness.KubeconfigPath(flags.Dir), harness.PullSecretPath(flags.Dir))
+
+	harness.NewController(h.HiveClient, h.K8sClient, h.Kubeconfig, flags.Controller).Run(ctx)
+	log.Info("Harness stopped.")
+
+	return nil
+}
@@ -3,12 +3,11 @@ package bundles
 import (
 	"audit-tool-orchestrator/pkg"
 	"audit-tool-orchestrator/pkg/index"
-	"database/sql"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
 )
 
 var flags = index.BundleFlags{}
@@ -28,13 +27,24 @@ func NewCmd() *cobra.Command {
 		log.Fatalf("Failed to mark `index-image` flag for `index` sub-command as required")
 	}
 
-	cmd.Flags().StringVar(&flags.OutputPath, "output-path", "",
+	cmd.Flags().StringVar(&flags.OutputPath, "output-path", index.DefaultOutputPath,
 		"inform the path of the directory to output the report.")
+	cmd.Flags().StringVar(&flags.OutputFile, "output-file", "",
+		fmt.Sprintf("name of the file the bundle list is written to. Defaults to %s.<output-format>. "+
+			"Use %s to write the list to the standard output.", index.DefaultOutputName, index.Stdout))
+	cmd.Flags().StringVar(&flags.OutputFormat, "output-format", pkg.JSON,
+		fmt.Sprintf("format of the bundle list. [Options: %s]", strings.Join(index.OutputFormats, ", ")))
 
 	cmd.Flags().StringVar(&flags.ContainerEngine, "container-engine", pkg.Docker,
 		fmt.Sprintf("specifies the container tool to use. If not set, the default value is docker. "+
 			"Note that you can use the environment variable CONTAINER_ENGINE to inform this option. "+
-			"[Options: %s and %s]", pkg.Docker, pkg.Podman))
+			"Use %s to read the index image straight from the registry, or from a local OCI layout (oci:<dir>) "+
+			"or docker save tarball (docker-archive:<file>), without a container tool. "+
+			"[Options: %s, %s and %s]", pkg.None, pkg.Docker, pkg.Podman, pkg.None))
+
+	cmd.Flags().StringVar(&flags.Mode, "mode", index.HeadsMode,
+		fmt.Sprintf("bundles of the index to list. [Options: %s]", strings.Join(index.Modes, ", ")))
+	index.AddFilterFlags(cmd, &flags.Filter)
 
 	return cmd
 }
@@ -46,13 +56,28 @@ func validation(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if !index.IsValidOutputFormat(flags.OutputFormat) {
+		return fmt.Errorf("invalid value for the flag --output-format (%s). The valid options are %s",
+			flags.OutputFormat, strings.Join(index.OutputFormats, ", "))
+	}
+
 	if len(flags.ContainerEngine) == 0 {
 		flags.ContainerEngine = pkg.GetContainerToolFromEnvVar()
 	}
 
-	if flags.ContainerEngine != pkg.Docker && flags.ContainerEngine != pkg.Podman {
+	if flags.ContainerEngine != pkg.Docker && flags.ContainerEngine != pkg.Podman &&
+		flags.ContainerEngine != pkg.None {
 		return fmt.Errorf("invalid value for the flag --container-engine (%s)."+
-			" The valid options are %s and %s", flags.ContainerEngine, pkg.Docker, pkg.Podman)
+			" The valid options are %s, %s and %s", flags.ContainerEngine, pkg.Docker, pkg.Podman, pkg.None)
+	}
+
+	if !index.IsValidMode(flags.Mode) {
+		return fmt.Errorf("invalid value for the flag --mode (%s). The valid options are %s",
+			flags.Mode, strings.Join(index.Modes, ", "))
+	}
+
+	if err := flags.Filter.Validate(); err != nil {
+		return err
 	}
 
 	return nil
@@ -62,91 +87,23 @@ func run(cmd *cobra.Command, args []string) error {
 	pkg.CleanupTemporaryDirs()
 	pkg.GenerateTemporaryDirs()
 
-	if err := index.DownloadImage(flags.IndexImage, flags.ContainerEngine); err != nil {
+	bundlelist, err := index.GetDataFromIndexImage(flags.IndexImage, flags.ContainerEngine, flags.Mode)
+	if err != nil {
 		return err
 	}
 
-	if err := index.ExtractIndexDB(flags.IndexImage, flags.ContainerEngine); err != nil {
-		return err
-	}
+	bundlelist = bundlelist.Filter(flags.Filter)
 
-	bundlelist := index.BundleList{}
-	bundlelist, err := getDataFromIndexDB(bundlelist)
+	path, err := bundlelist.OutputList(flags.OutputPath, flags.OutputFile, flags.OutputFormat)
 	if err != nil {
 		return err
 	}
 
-	if err := bundlelist.OutputList(); err != nil {
-		return err
+	if path != index.Stdout {
+		log.Infof("Bundle list written to %s", path)
 	}
 
 	pkg.CleanupTemporaryDirs()
 
 	return nil
 }
-
-func getDataFromIndexDB(data index.BundleList) (index.BundleList, error) {
-	// Connect to the database
-	db, err := sql.Open("sqlite3", "/tmp/ato/output/index.db")
-	if err != nil {
-		return data, fmt.Errorf("unable to connect in to the database : %s", err)
-	}
-
-	query, err := index.BuildBundlesQuery()
-	if err != nil {
-		return data, err
-	}
-
-	row, err := db.Query(query)
-	if err != nil {
-		return data, fmt.Errorf("unable to query the index db : %s", err)
-	}
-
-	defer row.Close()
-	for row.Next() {
-		var bundleName string
-		var bundlePath string
-
-		err = row.Scan(&bundleName, &bundlePath)
-		if err != nil {
-			log.Errorf("unable to scan data from index %s\n", err.Error())
-		}
-		log.Infof("Generating data from the bundle (%s)", bundleName)
-		bundle := index.NewBundle(bundleName, bundlePath)
-
-		query = fmt.Sprintf("SELECT c.channel_name, c.package_name FROM channel_entry c "+
-			"where c.operatorbundle_name = '%s'", bundle.Name)
-		row, err := db.Query(query)
-		if err != nil {
-			return data, fmt.Errorf("unable to query channel entry in the index db : %s", err)
-		}
-
-		defer row.Close()
-		var channelName string
-		var packageName string
-		for row.Next() { // Iterate and fetch the records from result cursor
-			_ = row.Scan(&channelName, &packageName)
-			bundle.Channels = append(bundle.Channels, channelName)
-			bundle.PackageName = packageName
-		}
-
-		query = fmt.Sprintf("SELECT default_channel FROM package WHERE name = '%s'", bundle.PackageName)
-		row, err = db.Query(query)
-		if err != nil {
-			return data, fmt.Errorf("unable to query default channel entry in the index db : %s", err)
-		}
-
-		defer row.Close()
-		var defaultChannelName string
-		for row.Next() { // Iterate and fetch the records from result cursor
-			_ = row.Scan(&defaultChannelName)
-			bundle.DefaultChannel = defaultChannelName
-		}
-
-		defer row.Close()
-
-		data.Bundles = append(data.Bundles, *bundle)
-	}
-
-	return data, nil
-}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"audit-tool-orchestrator/pkg"
+	"audit-tool-orchestrator/pkg/index"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+)
+
+var flags = index.DiffFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Report what changed between two index images.",
+		Long: "Compare the packages, bundles, channel heads and default channels of two index images and write " +
+			"the bundle list of the new or changed channel heads, which can be audited with `orchestrate run`.",
+		PreRunE: validation,
+		RunE:    run,
+	}
+
+	cmd.Flags().StringVar(&flags.From, "from", "",
+		"index image and tag of the previous catalog")
+	if err := cmd.MarkFlagRequired("from"); err != nil {
+		log.Fatalf("Failed to mark `from` flag for `diff` sub-command as required")
+	}
+
+	cmd.Flags().StringVar(&flags.To, "to", "",
+		"index image and tag of the new catalog")
+	if err := cmd.MarkFlagRequired("to"); err != nil {
+		log.Fatalf("Failed to mark `to` flag for `diff` sub-command as required")
+	}
+
+	cmd.Flags().StringVar(&flags.OutputPath, "output-path", index.DefaultOutputPath,
+		"inform the path of the directory to output the report.")
+	cmd.Flags().StringVar(&flags.OutputFile, "output-file", "",
+		fmt.Sprintf("name of the file the bundle list of the changed heads is written to. "+
+			"Defaults to %s-diff.<output-format>. Use %s to write the list to the standard output.",
+			index.DefaultOutputName, index.Stdout))
+	cmd.Flags().StringVar(&flags.OutputFormat, "output-format", pkg.JSON,
+		fmt.Sprintf("format of the bundle list. [Options: %s]", strings.Join(index.OutputFormats, ", ")))
+
+	cmd.Flags().StringVar(&flags.ContainerEngine, "container-engine", pkg.Docker,
+		fmt.Sprintf("specifies the container tool to use. If not set, the default value is docker. "+
+			"Note that you can use the environment variable CONTAINER_ENGINE to inform this option. "+
+			"Use %s to read the index images straight from the registry, or from a local OCI layout (oci:<dir>) "+
+			"or docker save tarball (docker-archive:<file>), without a container tool. "+
+			"[Options: %s, %s and %s]", pkg.None, pkg.Docker, pkg.Podman, pkg.None))
+
+	return cmd
+}
+
+func validation(cmd *cobra.Command, args []string) error {
+	if len(flags.OutputPath) > 0 {
+		if _, err := os.Stat(flags.OutputPath); os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if !index.IsValidOutputFormat(flags.OutputFormat) {
+		return fmt.Errorf("invalid value for the flag --output-format (%s). The valid options are %s",
+			flags.OutputFormat, strings.Join(index.OutputFormats, ", "))
+	}
+
+	if len(flags.ContainerEngine) == 0 {
+		flags.ContainerEngine = pkg.GetContainerToolFromEnvVar()
+	}
+
+	if flags.ContainerEngine != pkg.Docker && flags.ContainerEngine != pkg.Podman &&
+		flags.ContainerEngine != pkg.None {
+		return fmt.Errorf("invalid value for the flag --container-engine (%s)."+
+			" The valid options are %s, %s and %s", flags.ContainerEngine, pkg.Docker, pkg.Podman, pkg.None)
+	}
+
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	pkg.CleanupTemporaryDirs()
+	pkg.GenerateTemporaryDirs()
+	// the indexes extracted in the temporary directories are removed when the diff fails too
+	defer os.RemoveAll(pkg.TemporaryDir)
+
+	from, err := index.GetCatalogFromIndexImage(flags.From, flags.ContainerEngine)
+	if err != nil {
+		return err
+	}
+
+	to, err := index.GetCatalogFromIndexImage(flags.To, flags.ContainerEngine)
+	if err != nil {
+		return err
+	}
+
+	diff := index.DiffCatalogs(from, to)
+
+	// keep the standard output for the bundle list when it is written there
+	var out io.Writer = os.Stdout
+	if flags.OutputFile == index.Stdout {
+		out = os.Stderr
+	}
+
+	if err := diff.Print(out); err != nil {
+		return err
+	}
+
+	if len(flags.OutputFile) == 0 {
+		flags.OutputFile = index.DefaultOutputName + "-diff." + flags.OutputFormat
+	}
+
+	path, err := diff.Heads.OutputList(flags.OutputPath, flags.OutputFile, flags.OutputFormat)
+	if err != nil {
+		return err
+	}
+
+	if path != index.Stdout {
+		log.Infof("Bundle list of the changed heads written to %s", path)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package index
 
 import (
 	"audit-tool-orchestrator/cmd/index/bundles"
+	"audit-tool-orchestrator/cmd/index/diff"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +15,7 @@ func NewCmd() *cobra.Command {
 
 	indexCmd.AddCommand(
 		bundles.NewCmd(),
+		diff.NewCmd(),
 	)
 
 	return indexCmd
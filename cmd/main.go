@@ -1,21 +1,38 @@
 package main
 
 import (
+	"audit-tool-orchestrator/cmd/config"
+	"audit-tool-orchestrator/cmd/harness"
 	"audit-tool-orchestrator/cmd/index"
 	"audit-tool-orchestrator/cmd/orchestrate"
+	"audit-tool-orchestrator/cmd/report"
+	"audit-tool-orchestrator/cmd/results"
+	atoconfig "audit-tool-orchestrator/pkg/config"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var configFlags = atoconfig.ConfigFlags{}
+
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "audit-tool-orchestrator",
 		Short: "orchestrate running audit-tool against openshift",
 		Long:  "",
+		// the flags not set on the command line are taken from the config file
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return atoconfig.Apply(cmd, configFlags)
+		},
 	}
 
+	atoconfig.AddConfigFlags(rootCmd, &configFlags)
+
+	rootCmd.AddCommand(config.NewCmd())
+	rootCmd.AddCommand(harness.NewCmd())
 	rootCmd.AddCommand(index.NewCmd())
 	rootCmd.AddCommand(orchestrate.NewCmd())
+	rootCmd.AddCommand(report.NewCmd())
+	rootCmd.AddCommand(results.NewCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
@@ -3,14 +3,12 @@ package claim
 // create and delete ClusterClaim resource
 
 import (
+	"audit-tool-orchestrator/pkg/bucket"
 	"audit-tool-orchestrator/pkg/orchestrate"
 	"context"
-	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"os"
 	"strings"
 )
 
@@ -38,6 +36,18 @@ func NewCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&flags.Delete, "delete", false,
 		"Delete the ClusterClaim provided by the name flag. If you do not provide the name and set "+
 			"the --delete flag command will fail.")
+	cmd.Flags().StringVar(&flags.JobNamespace, "job-namespace", orchestrate.DefaultJobNamespace,
+		"namespace of the audit Jobs on the claimed cluster, where the kubeconfig, registry pull and bucket "+
+			"credentials Secrets are added.")
+	cmd.Flags().StringVar(&flags.BucketCredentials.File, "bucket-credentials-file", "",
+		fmt.Sprintf("env file with the %s, %s and %s of the bucket, one KEY=value per line, provisioned as the "+
+			"%s Secret on the claimed cluster. If neither this flag nor --bucket-credentials-secret is set, the "+
+			"environment variables are used when they are set.",
+			bucket.EndpointEnvVar, bucket.AccessKeyIDEnvVar, bucket.SecretAccessKeyEnvVar,
+			bucket.SecretName))
+	cmd.Flags().StringVar(&flags.BucketCredentials.Secret, "bucket-credentials-secret", "",
+		"<namespace>/<name> of a Secret on the Hive cluster with the bucket credentials, which takes precedence "+
+			"over --bucket-credentials-file.")
 
 	return cmd
 }
@@ -64,92 +74,61 @@ func validation(cmd *cobra.Command, args []string) error {
 
 func run(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
-
-	hvclient := orchestrate.GetHiveClient()
-
-	cc := hivev1.ClusterClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      flags.Name,
-			Namespace: "hive",
-			Labels:    map[string]string{"bundle-name": flags.BundleName},
-		},
-		Spec: hivev1.ClusterClaimSpec{
-			ClusterPoolName: flags.PoolName,
-		},
+	hvclient, err := orchestrate.GetHiveClient()
+	if err != nil {
+		return err
 	}
 
 	if flags.Delete {
-		err := hvclient.HiveV1().ClusterClaims("hive").Delete(ctx, flags.Name, metav1.DeleteOptions{})
-		if err != nil {
+		if err := orchestrate.DeleteClusterClaim(ctx, hvclient, flags.Name, flags.Namespace); err != nil {
 			log.Errorf("Unable to delete ClusterClaim %s: %v\n", flags.Name, err)
 			return err
 		}
 
-		log.Infof("ClusterClaim %s deleted.\n", flags.Name)
-
 		return nil
-	} else {
-		_, err := hvclient.HiveV1().ClusterClaims(flags.Namespace).Create(ctx, &cc, metav1.CreateOptions{})
-		if err != nil {
-			log.Errorf("Unable to create ClusterClaim %s: %v\n", flags.Name, err)
-			return err
-		}
-
-		log.Infof("ClusterClaim %s submitted. Waiting for Pending and ClusterRunning statuses", flags.Name)
 	}
 
-	// ClusterClaim was submitted, we need to wait for Pending (False) and ClusterRunning (True) statuses
-	claim, err := hvclient.HiveV1().ClusterClaims(flags.Namespace).Get(ctx, flags.Name, metav1.GetOptions{})
+	// fail before waiting for a cluster when the bucket credentials set can not be read
+	k8sclient, err := orchestrate.GetK8sClient()
 	if err != nil {
-		log.Errorf("Unable to get ClusterClaim: %v\n", err)
+		return err
 	}
 
-	cdNameNamespace, err := orchestrate.WaitForSuccessfulClusterClaim(hvclient, claim)
+	credentials, err := bucket.LoadCredentials(ctx, k8sclient, flags.BucketCredentials)
 	if err != nil {
-		log.Fatalf("ClusterClaim Watch returned an error: %v\n", err)
+		if flags.BucketCredentials.IsSet() {
+			return err
+		}
+
+		// the audit Jobs which use the bucket provision its credentials themselves
+		log.Infof("No bucket credentials provisioned on the claimed cluster: %v\n", err)
+		credentials = bucket.Credentials{}
 	}
-	log.Infof("ClusterClaim succeeded. ClusterDeployment %s will be used.\n", cdNameNamespace)
 
-	clusterDeployment, err := hvclient.HiveV1().ClusterDeployments(cdNameNamespace).Get(ctx, cdNameNamespace, metav1.GetOptions{})
+	// ClusterClaim is submitted, we need to wait for Pending (False) and ClusterRunning (True) statuses
+	cdNameNamespace, err := orchestrate.ClaimClusterForBundle(ctx, hvclient, flags)
 	if err != nil {
-		log.Errorf("Unable to get ClusterDeployment: %s\n", cdNameNamespace)
+		log.Errorf("ClusterClaim returned an error: %v\n", err)
+		return err
 	}
+	log.Infof("ClusterClaim succeeded. ClusterDeployment %s will be used.\n", cdNameNamespace)
 
-	kubeconfigSecret := clusterDeployment.Spec.ClusterMetadata.AdminKubeconfigSecretRef
-
-	k8sclient := orchestrate.GetK8sClient()
-	kubeconfig, err := k8sclient.CoreV1().Secrets(cdNameNamespace).Get(ctx, kubeconfigSecret.Name, metav1.GetOptions{})
+	kubeconfig, err := orchestrate.GetClusterUnderTestKubeconfig(ctx, hvclient, k8sclient, cdNameNamespace)
 	if err != nil {
 		log.Errorf("Unable to get kubeconfig for cluster under test: %v\n", err)
+		return err
 	}
 
-	auditKubeconfig := corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "kubeconfig",
-		},
-		StringData: map[string]string{"config": string(kubeconfig.Data["raw-kubeconfig"])},
-		Type:       "Opaque",
-	}
-
-	auditClient := orchestrate.K8sClientForAudit(kubeconfig.Data["raw-kubeconfig"])
-	auditClient.CoreV1().Secrets("default").Create(ctx, &auditKubeconfig, metav1.CreateOptions{})
-
-	// TODO: get from secret
-	registryPullSecret, err := os.ReadFile(os.Getenv("REGISTRY_PULL_SECRET"))
+	auditClient, err := orchestrate.K8sClientForAudit(kubeconfig)
 	if err != nil {
-		log.Errorf("Unable to get registry pull secret: %v\n", err)
+		log.Errorf("Unable to reach the cluster under test: %v\n", err)
+		return err
 	}
 
-	auditImagePullSecret := corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "registry-pull-secret",
-		},
-		StringData: map[string]string{".dockerconfigjson": string(registryPullSecret)},
-		Type:       "kubernetes.io/dockerconfigjson",
-	}
-	_, err = auditClient.CoreV1().Secrets("default").Create(ctx, &auditImagePullSecret, metav1.CreateOptions{})
-	if err != nil {
-		log.Errorf("Unable to add registry image pull secret to cluster under test: %v\n", err)
+	if err := orchestrate.PrepareClusterUnderTest(ctx, auditClient, flags.JobNamespace, kubeconfig,
+		credentials); err != nil {
+		log.Errorf("Unable to prepare cluster under test: %v\n", err)
+		return err
 	}
 
 	return nil
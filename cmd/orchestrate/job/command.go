@@ -1,14 +1,19 @@
 package job
 
 import (
+	"audit-tool-orchestrator/pkg/artifacts"
+	"audit-tool-orchestrator/pkg/bucket"
 	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/report"
+	"audit-tool-orchestrator/pkg/suite"
 	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	batchv1 "k8s.io/api/batch/v1"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"os"
+	"strings"
+	"time"
 )
 
 var flags = orchestrate.JobFlags{}
@@ -34,130 +39,172 @@ func NewCmd() *cobra.Command {
 		"ClusterClaim resource to be used for audit job.")
 	cmd.Flags().StringVar(&flags.Kubeconfig, "kubeconfig", "",
 		"Kubeconfig to use for creating Job resource.")
+	cmd.Flags().StringVar(&flags.Template, "template", "",
+		"Go template of the Job resource in YAML. The variables .Name, .BundleImage, .BundleName, .BucketName "+
+			"and .ClaimName are available. If not set, the built-in capabilities-tool Job is used.")
+	cmd.Flags().StringSliceVar(&flags.Suites, "suites", []string{},
+		fmt.Sprintf("audit suites to run against the bundle instead of the single Job, each one in its own Job. "+
+			"[Options: %s]", strings.Join(suite.Names(), ", ")))
+	cmd.Flags().BoolVar(&flags.Parallel, "parallel", false,
+		"run the suites at the same time instead of one after the other.")
+	cmd.Flags().DurationVar(&flags.Deadline, "deadline", 0,
+		"active deadline of the Job, after which it is failed. If not set, the deadline of the Job template, "+
+			"or the timeout of the suite, is used.")
+	cmd.Flags().IntVar(&flags.Retries, "retries", orchestrate.DefaultJobRetries,
+		"number of times the Job is run again when it fails for infrastructure reasons, such as image pull "+
+			"errors or evictions. Audit failures are not retried.")
+	cmd.Flags().BoolVar(&flags.FollowLogs, "follow-logs", true,
+		"stream the container logs of the Job to stdout while it runs.")
+	cmd.Flags().StringVar(&flags.ArtifactsDir, "artifacts-dir", artifacts.DefaultDir(),
+		"directory where the logs, the Job and Pod YAML and the namespace events are saved once the Job "+
+			"finished, under <bundle-name>/<job-name>. Set it empty to not save them.")
+	cmd.Flags().BoolVar(&flags.UploadArtifacts, "upload-artifacts", false,
+		"also upload the artifacts to the bucket set with --bucket-name, under <bundle-name>/artifacts/<job-name>.")
+	cmd.Flags().BoolVar(&flags.Secure, "secure", true,
+		"use TLS to reach the S3 (minio) compatible endpoint when uploading the artifacts.")
+	cmd.Flags().StringVar(&flags.BucketCredentials.File, "bucket-credentials-file", "",
+		fmt.Sprintf("env file with the %s, %s and %s of the bucket, one KEY=value per line, provisioned as the "+
+			"%s Secret on the cluster under test when the Job template or suites use the bucket and used to upload "+
+			"the artifacts. If neither this flag nor --bucket-credentials-secret is set, the environment variables "+
+			"are used.",
+			bucket.EndpointEnvVar, bucket.AccessKeyIDEnvVar, bucket.SecretAccessKeyEnvVar,
+			bucket.SecretName))
+	cmd.Flags().StringVar(&flags.BucketCredentials.Secret, "bucket-credentials-secret", "",
+		"<namespace>/<name> of a Secret on the Hive cluster with the bucket credentials, which takes precedence "+
+			"over --bucket-credentials-file.")
+	cmd.Flags().StringVar(&flags.JUnit, "junit", "",
+		"path of a JUnit XML report written once the Job finished, with a test case per suite, with the Job "+
+			"condition reason and the end of the audit logs in the failures.")
 
 	return cmd
 }
 
 func validation(cmd *cobra.Command, args []string) error {
+	if len(flags.Template) > 0 {
+		if _, err := os.Stat(flags.Template); os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if len(flags.Suites) > 0 && len(flags.Template) > 0 {
+		return fmt.Errorf("the flags --suites and --template cannot be used together")
+	}
+
+	if flags.Deadline < 0 || flags.Retries < 0 {
+		return fmt.Errorf("the flags --deadline and --retries cannot be negative")
+	}
+
+	if flags.UploadArtifacts && (len(flags.BucketName) == 0 || len(flags.ArtifactsDir) == 0) {
+		return fmt.Errorf("the flag --upload-artifacts requires --bucket-name and --artifacts-dir")
+	}
+
+	for _, name := range flags.Suites {
+		if _, err := suite.Get(name); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func run(cmd *cobra.Command, args []string) error {
-	ctx := context.Background()
 	kubeconfig, err := os.ReadFile(flags.Kubeconfig)
 	if err != nil {
 		log.Fatalf("Kubeconfig required to create Job resource: %v\n", err)
 	}
 
-	auditClient := orchestrate.K8sClientForAudit(kubeconfig)
-
-	jobBackoffLimit := int32(1)
-	jobPrivileged := true
-	logEndpoint := &corev1.EnvVarSource{
-		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-			LocalObjectReference: corev1.LocalObjectReference{
-				Name: "env-var",
-			},
-			Key: "MINIO_ENDPOINT",
-		},
-	}
-	logAccessKeyID := &corev1.EnvVarSource{
-		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-			LocalObjectReference: corev1.LocalObjectReference{
-				Name: "env-var",
-			},
-			Key: "MINIO_ACCESS_KEY_ID",
-		},
-	}
-	logSecretAccessKey := &corev1.EnvVarSource{
-		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-			LocalObjectReference: corev1.LocalObjectReference{
-				Name: "env-var",
-			},
-			Key: "MINIO_SECRET_ACCESS_KEY",
-		},
-	}
-	auditJob := batchv1.Job{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      flags.Name,
-			Namespace: "default",
-		},
-		Spec: batchv1.JobSpec{
-			BackoffLimit: &jobBackoffLimit,
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   "audit-tool-job-pod",
-					Labels: map[string]string{"operator": flags.Name},
-				},
-				Spec: corev1.PodSpec{
-					Volumes: []corev1.Volume{
-						{
-							Name: "docker-config",
-							VolumeSource: corev1.VolumeSource{
-								Secret: &corev1.SecretVolumeSource{
-									SecretName: "registry-pull-secret",
-									Items: []corev1.KeyToPath{
-										{Key: ".dockerconfigjson", Path: "config.json"},
-									},
-								},
-							},
-						},
-						{
-							Name: "kube-config",
-							VolumeSource: corev1.VolumeSource{
-								Secret: &corev1.SecretVolumeSource{
-									SecretName: "kubeconfig",
-									Items: []corev1.KeyToPath{
-										{Key: "config", Path: "config"},
-									},
-								},
-							},
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:  "audit-tool",
-							Image: "quay.io/opdev/capabilities-tool:v1.0.0",
-							Args: []string{
-								"index",
-								"capabilities",
-								"--container-engine",
-								"podman",
-								"--output-path",
-								"/opt/capabilities-tool",
-								"--bundle-image",
-								flags.BundleImage,
-								"--bucket-name",
-								flags.BucketName,
-								"--bundle-name",
-								flags.BundleName,
-							},
-							Env: []corev1.EnvVar{
-								{Name: "MINIO_ENDPOINT", ValueFrom: logEndpoint},
-								{Name: "MINIO_ACCESS_KEY_ID", ValueFrom: logAccessKeyID},
-								{Name: "MINIO_SECRET_ACCESS_KEY", ValueFrom: logSecretAccessKey},
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{Name: "docker-config", MountPath: "/opt/capabilities-tool/.docker/"},
-								{Name: "kube-config", MountPath: "/opt/capabilities-tool/.kube/"},
-							},
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &jobPrivileged,
-							},
-						},
-					},
-					RestartPolicy: "Never",
-				},
-			},
-		},
-	}
-
-	job, err := auditClient.BatchV1().Jobs("default").Create(ctx, &auditJob, metav1.CreateOptions{})
+	auditClient, err := orchestrate.K8sClientForAudit(kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	if err := provisionBucketCredentials(auditClient); err != nil {
+		return err
+	}
+
+	if len(flags.Suites) > 0 {
+		results, err := suite.RunAll(context.Background(), auditClient, flags)
+		if err != nil {
+			return err
+		}
+
+		if err := writeJUnit(results); err != nil {
+			return err
+		}
+
+		return suite.PrintSummary(os.Stdout, results)
+	}
+
+	started := time.Now()
+	status, err := orchestrate.RunAuditJob(context.Background(), auditClient, flags)
 	if err != nil {
 		return err
 	}
 
-	_ = orchestrate.WaitForAuditJob(auditClient, job)
+	log.Infof("Job %s finished with result %s %s %s\n", flags.Name, status.Result, status.Reason, status.Message)
+
+	return writeJUnit([]suite.Result{{
+		Suite:     flags.Name,
+		JobName:   flags.Name,
+		Duration:  time.Since(started),
+		JobStatus: status,
+	}})
+}
+
+// provisionBucketCredentials loads the bucket credentials when the artifacts are uploaded, and adds them to the
+// namespaces of the Jobs on the cluster under test when the Job template or the suites use the bucket
+func provisionBucketCredentials(auditClient kubernetes.Interface) error {
+	namespaces, err := bucketNamespaces()
+	if err != nil || len(namespaces) == 0 && !flags.UploadArtifacts {
+		return err
+	}
+
+	// the Hive cluster is only reached when the credentials are read from a Secret on it
+	var hiveK8sClient kubernetes.Interface
+	if len(flags.BucketCredentials.Secret) > 0 {
+		hiveK8sClient, err = orchestrate.GetK8sClient()
+		if err != nil {
+			return err
+		}
+	}
+
+	flags.Credentials, err = bucket.LoadCredentials(context.Background(), hiveK8sClient, flags.BucketCredentials)
+	if err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		if err := bucket.EnsureSecret(context.Background(), auditClient, namespace, flags.Credentials); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+// bucketNamespaces returns the namespaces of the rendered Jobs which use the bucket
+func bucketNamespaces() ([]string, error) {
+	if len(flags.Suites) > 0 {
+		return suite.BucketNamespaces(flags)
+	}
+
+	usesBucket, err := flags.UsesBucket()
+	if err != nil || !usesBucket {
+		return nil, err
+	}
+
+	auditJob, err := orchestrate.NewAuditJob(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{auditJob.Namespace}, nil
+}
+
+func writeJUnit(results []suite.Result) error {
+	if len(flags.JUnit) == 0 {
+		return nil
+	}
+
+	return report.SuitesJUnit(flags, results).WriteFile(flags.JUnit)
+}
@@ -4,6 +4,9 @@ import (
 	"audit-tool-orchestrator/cmd/orchestrate/claim"
 	"audit-tool-orchestrator/cmd/orchestrate/job"
 	"audit-tool-orchestrator/cmd/orchestrate/pool"
+	"audit-tool-orchestrator/cmd/orchestrate/resume"
+	"audit-tool-orchestrator/cmd/orchestrate/run"
+	"audit-tool-orchestrator/cmd/orchestrate/verify"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +21,9 @@ func NewCmd() *cobra.Command {
 		pool.NewCmd(),
 		claim.NewCmd(),
 		job.NewCmd(),
+		run.NewCmd(),
+		resume.NewCmd(),
+		verify.NewCmd(),
 	)
 
 	return orchestrateCmd
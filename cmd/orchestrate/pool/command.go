@@ -4,16 +4,13 @@ package pool
 
 import (
 	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/release"
 	"context"
-	hivev1 "github.com/openshift/hive/apis/hive/v1"
-	"github.com/openshift/hive/apis/hive/v1/aws"
-	"github.com/openshift/hive/apis/hive/v1/azure"
-	"github.com/openshift/hive/apis/hive/v1/gcp"
-	"github.com/openshift/hive/apis/hive/v1/ibmcloud"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
 )
 
 var flags = orchestrate.PoolFlags{}
@@ -28,113 +25,77 @@ func NewCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&flags.Name, "name", "ato-cluster-pool",
-		"")
+		"name of the ClusterPool resource.")
 	cmd.Flags().StringVar(&flags.Namespace, "namespace", "hive",
-		"")
+		"OpenShift project (namespace) the ClusterPool should be created in.")
 	cmd.Flags().StringVar(&flags.BaseDomain, "basedomain", "coreostrain.me",
-		"")
-	cmd.Flags().StringVar(&flags.OpenShift, "openshift", "",
-		"")
+		"base DNS domain of the clusters of the ClusterPool, which must be managed by the cloud account of the "+
+			"platform.")
+	cmd.Flags().StringVar(&flags.OpenShift, "openshift", release.LatestVersion,
+		"OpenShift version of the clusters: latest, a minor version such as 4.10 or 4.10.x, or a release such "+
+			"as 4.10.3. The ClusterImageSet of the Hive cluster with the highest matching release is used.")
 	cmd.Flags().StringVar(&flags.InstallConfig, "install-config", "ato-install-config",
-		"")
+		"Secret with the install-config.yaml template used to install the clusters of the ClusterPool.")
 	cmd.Flags().StringVar(&flags.ImagePullSecret, "image-pull-secret", "hive-install-config-global-pullsecret",
-		"")
+		"Secret with the pull secret used to pull the OpenShift release images when installing the clusters.")
 	cmd.Flags().StringVar(&flags.Platform, "platform", "",
-		"")
+		fmt.Sprintf("cloud platform of the clusters of the ClusterPool. If not set, %s is used. [Options: %s]",
+			orchestrate.DefaultPlatform, strings.Join(orchestrate.Platforms, ", ")))
 	cmd.Flags().StringVar(&flags.Credentials, "credentials", "",
-		"")
+		"Secret with the cloud credentials of the platform used by Hive to install the clusters.")
 	cmd.Flags().StringVar(&flags.Region, "region", "",
-		"")
+		"cloud region of the platform the clusters of the ClusterPool are installed in.")
 	cmd.Flags().Int32Var(&flags.Running, "running", 0,
-		"")
+		"number of clusters of the ClusterPool kept running and ready to be claimed; the others are hibernating.")
 	cmd.Flags().Int32Var(&flags.Size, "size", 0,
-		"")
+		"number of unclaimed clusters kept installed by the ClusterPool.")
 	cmd.Flags().StringVar(&flags.IBMAccountID, "ibmaccountid", "",
-		"")
+		"IBM Cloud account ID of the clusters, required when --platform is ibm.")
 	cmd.Flags().StringVar(&flags.IBMCISInstanceCRN, "ibmcisinstancecrn", "",
-		"")
+		"CRN of the IBM Cloud Internet Services instance managing the base domain, required when --platform "+
+			"is ibm.")
+	cmd.Flags().BoolVar(&flags.CreateImageSet, "create-image-set", false,
+		"create the ClusterImageSet of the OpenShift version when the Hive cluster has none, from --release-image "+
+			"or else from the release published on mirror.openshift.com.")
+	cmd.Flags().StringVar(&flags.ReleaseImage, "release-image", "",
+		"release image of the created ClusterImageSet, which requires --openshift to be a release such as 4.10.3.")
 
 	return cmd
 }
 
-func setPlatform(platform string, flags orchestrate.PoolFlags) hivev1.Platform {
-	switch platform {
-	case "aws":
-		aws := &aws.Platform{
-			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
-			Region:               flags.Region,
-		}
-
-		return hivev1.Platform{AWS: aws}
-	case "azure":
-		azure := &azure.Platform{
-			CredentialsSecretRef:        corev1.LocalObjectReference{Name: flags.Credentials},
-			Region:                      flags.Region,
-			BaseDomainResourceGroupName: flags.AzureBaseDomainResourceGroupName,
-			CloudName:                   flags.AzureCloudName,
-		}
-
-		return hivev1.Platform{Azure: azure}
-	case "gcp":
-		gcp := &gcp.Platform{
-			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
-			Region:               flags.Region,
-		}
-
-		return hivev1.Platform{GCP: gcp}
-	case "ibm":
-		ibm := &ibmcloud.Platform{
-			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
-			AccountID:            flags.IBMAccountID,
-			CISInstanceCRN:       flags.IBMCISInstanceCRN,
-			Region:               flags.Region,
-		}
-
-		return hivev1.Platform{IBMCloud: ibm}
-	}
-
-	return hivev1.Platform{
-		AWS: &aws.Platform{
-			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
-			Region:               flags.Region,
-		},
+func validation(cmd *cobra.Command, args []string) error {
+	if !release.IsValidOpenShiftVersion(flags.OpenShift) {
+		return fmt.Errorf("invalid value for the flag --openshift (%s). Use latest, a minor version such as "+
+			"4.10 or 4.10.x, or a release such as 4.10.3", flags.OpenShift)
 	}
-}
 
-func validation(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
 func run(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
-	hvclient := orchestrate.GetHiveClient()
-	osversion := "ocp-" + orchestrate.GetOpenShiftVersions(flags)
+	hvclient, err := orchestrate.GetHiveClient()
+	if err != nil {
+		return err
+	}
 
-	cp := hivev1.ClusterPool{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      flags.Name,
-			Namespace: flags.Namespace,
-		},
-		Spec: hivev1.ClusterPoolSpec{
-			Platform:                       setPlatform(flags.Platform, flags),
-			PullSecretRef:                  &corev1.LocalObjectReference{Name: flags.ImagePullSecret},
-			Size:                           flags.Size,
-			RunningCount:                   flags.Running,
-			BaseDomain:                     flags.BaseDomain,
-			ImageSetRef:                    hivev1.ClusterImageSetReference{Name: osversion},
-			InstallConfigSecretTemplateRef: &corev1.LocalObjectReference{Name: flags.InstallConfig},
-			SkipMachinePools:               true,
-		},
+	imageSetName, err := release.EnsureClusterImageSet(ctx, hvclient, flags.OpenShift, flags.ReleaseImage,
+		flags.CreateImageSet)
+	if err != nil {
+		return err
 	}
 
+	cp := orchestrate.NewClusterPool(flags, imageSetName)
+
 	if _, err := hvclient.HiveV1().ClusterPools(flags.Namespace).Create(ctx, &cp, metav1.CreateOptions{}); err != nil {
 		log.Errorf("Unable to create ClusterPool: %v\n", err)
 		return err
 	}
 
-	_, err := orchestrate.WaitForSuccessfulClusterPool(hvclient, &cp)
+	_, err = orchestrate.WaitForSuccessfulClusterPool(ctx, hvclient, &cp)
 	if err != nil {
-		log.Fatalf("ClusterPool Watch returned an error: %v\n", err)
+		log.Errorf("ClusterPool Watch returned an error: %v\n", err)
+		return err
 	}
 
 	return nil
@@ -0,0 +1,87 @@
+package resume
+
+// resume an interrupted run from the state database
+
+import (
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/report"
+	"audit-tool-orchestrator/pkg/state"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var stateDB string
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume <run-id>",
+		Short: "Resume an interrupted orchestration run.",
+		Long: "Continue the run recorded in the state database. Bundles with a final result are not audited " +
+			"again. The other bundles are queued again and audited by the workers of the resumed run, which " +
+			"claim their clusters again since the ClusterClaims are released when a run is interrupted, so a " +
+			"bundle may be audited on another cluster where its Job is created again. A ClusterClaim or Job is " +
+			"only reused when it still exists, e.g. when the interrupted run was killed.",
+		Args: cobra.ExactArgs(1),
+		RunE: run,
+	}
+
+	cmd.Flags().StringVar(&stateDB, "state-db", state.DefaultDBPath(),
+		"SQLite database recording the progress of the runs.")
+
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+
+	store, err := state.Open(stateDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	recorded, err := store.GetRun(runID)
+	if err != nil {
+		return err
+	}
+
+	flags := orchestrate.RunFlags{}
+	if err := json.Unmarshal([]byte(recorded.Flags), &flags); err != nil {
+		return fmt.Errorf("unable to read the flags of run %s : %s", runID, err)
+	}
+
+	log.Infof("Resuming run %s of index image %s\n", runID, recorded.IndexImage)
+
+	// stop the workers and release their claims on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hvclient, err := orchestrate.GetHiveClient()
+	if err != nil {
+		return err
+	}
+
+	k8sclient, err := orchestrate.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	results, err := orchestrate.AuditRun(ctx, hvclient, k8sclient, store, runID, flags)
+	if printErr := orchestrate.PrintAuditSummary(os.Stdout, results); printErr != nil {
+		log.Errorf("Unable to print the audit summary: %v\n", printErr)
+	}
+
+	if len(flags.JUnit) > 0 {
+		if junitErr := report.WriteRunJUnit(store, runID, flags.JUnit); junitErr != nil {
+			log.Errorf("Unable to write the JUnit report: %v\n", junitErr)
+		}
+	}
+
+	return err
+}
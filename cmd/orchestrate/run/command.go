@@ -0,0 +1,327 @@
+package run
+
+// run the whole pipeline: index -> pool -> claim -> job
+
+import (
+	"audit-tool-orchestrator/pkg"
+	"audit-tool-orchestrator/pkg/artifacts"
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/matrix"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/release"
+	"audit-tool-orchestrator/pkg/report"
+	"audit-tool-orchestrator/pkg/state"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var flags = orchestrate.RunFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Audit every bundle of an index image on clusters claimed from a Hive ClusterPool.",
+		Long: "Read the bundles from the index image, or from a bundle list such as the one written by `index diff`, " +
+			"ensure the ClusterPool exists, claim clusters from it and run the audit Job of each bundle on a claimed " +
+			"cluster. Claims are released once every bundle has been audited. A summary with the result of each " +
+			"bundle is printed at the end. With --pool-matrix, a ClusterPool is used for each OpenShift version " +
+			"and platform of the matrix and each bundle is audited on every pool running an OpenShift version " +
+			"within the range of its com.redhat.openshift.versions annotation.",
+		PreRunE: validation,
+		RunE:    run,
+	}
+
+	cmd.Flags().StringVar(&flags.IndexImage, "index-image", "",
+		"index image and tag which will be audit. Required unless --bundle-list is set.")
+	cmd.Flags().StringVar(&flags.BundleList, "bundle-list", "",
+		"bundle list written by `index bundles` or `index diff` to audit instead of the bundles of the index image.")
+
+	cmd.Flags().StringVar(&flags.ContainerEngine, "container-engine", pkg.Docker,
+		fmt.Sprintf("specifies the container tool to use. If not set, the default value is docker. "+
+			"Note that you can use the environment variable CONTAINER_ENGINE to inform this option. "+
+			"Use %s to read the index image straight from the registry, or from a local OCI layout (oci:<dir>) "+
+			"or docker save tarball (docker-archive:<file>), without a container tool. "+
+			"[Options: %s, %s and %s]", pkg.None, pkg.Docker, pkg.Podman, pkg.None))
+	cmd.Flags().StringVar(&flags.Mode, "mode", index.HeadsMode,
+		fmt.Sprintf("bundles of the index to audit. [Options: %s]", strings.Join(index.Modes, ", ")))
+	index.AddFilterFlags(cmd, &flags.Filter)
+	cmd.Flags().StringVar(&flags.BucketName, "bucket-name", "",
+		"S3 (minio) compatible bucket to store logs.")
+	cmd.Flags().StringVar(&flags.JobTemplate, "job-template", "",
+		"Go template of the audit Job resource in YAML. The variables .Name, .BundleImage, .BundleName, "+
+			".BucketName and .ClaimName are available. If not set, the built-in capabilities-tool Job is used.")
+	cmd.Flags().DurationVar(&flags.JobDeadline, "job-deadline", 0,
+		"active deadline of the audit Jobs, after which they are failed. If not set, the deadline of the Job "+
+			"template is used.")
+	cmd.Flags().IntVar(&flags.JobRetries, "job-retries", orchestrate.DefaultJobRetries,
+		"number of times an audit Job is run again when it fails for infrastructure reasons, such as image "+
+			"pull errors or evictions. Audit failures are not retried.")
+	cmd.Flags().StringVar(&flags.ArtifactsDir, "artifacts-dir", artifacts.DefaultDir(),
+		"directory where the logs, the Job and Pod YAML and the namespace events of each audit Job are saved, "+
+			"under <run-id>/<bundle-name>/<job-name>. Set it empty to not save them.")
+	cmd.Flags().BoolVar(&flags.UploadArtifacts, "upload-artifacts", false,
+		"also upload the artifacts to the bucket set with --bucket-name, under "+
+			"<run-id>/<bundle-name>/artifacts/<job-name>.")
+	cmd.Flags().BoolVar(&flags.Secure, "secure", true,
+		"use TLS to reach the S3 (minio) compatible endpoint when uploading the artifacts.")
+	cmd.Flags().StringVar(&flags.BucketCredentials.File, "bucket-credentials-file", "",
+		fmt.Sprintf("env file with the %s, %s and %s of the bucket, one KEY=value per line, provisioned as the "+
+			"%s Secret on each claimed cluster when --bucket-name is set or the Job template uses the bucket. If "+
+			"neither this flag nor --bucket-credentials-secret is set, the environment variables are used.",
+			bucket.EndpointEnvVar, bucket.AccessKeyIDEnvVar, bucket.SecretAccessKeyEnvVar,
+			bucket.SecretName))
+	cmd.Flags().StringVar(&flags.BucketCredentials.Secret, "bucket-credentials-secret", "",
+		"<namespace>/<name> of a Secret on the Hive cluster with the bucket credentials, which takes precedence "+
+			"over --bucket-credentials-file.")
+	cmd.Flags().StringVar(&flags.JUnit, "junit", "",
+		"path of a JUnit XML report written when the run ends, with a test suite per package and a test case "+
+			"per bundle, with the Job condition reason and the end of the audit logs in the failures.")
+	cmd.Flags().StringVar(&flags.StateDB, "state-db", state.DefaultDBPath(),
+		"SQLite database recording the progress of the run so it can be resumed.")
+	cmd.Flags().IntVar(&flags.Workers, "workers", 0,
+		"number of bundles audited at the same time on each ClusterPool, each one on its own claimed cluster. "+
+			"Defaults to the size of the ClusterPool or, when not set, its running count.")
+	cmd.Flags().StringVar(&flags.PoolMatrix, "pool-matrix", "",
+		"YAML file of the ClusterPools to audit the bundles on: the pool name prefix (name), the OpenShift "+
+			"versions (openshift, e.g. [4.9, 4.10]) and the pool settings of each platform (platforms, e.g. "+
+			"platform, region and credentials) set on top of the pool flags. A pool named "+
+			"<name>-<version>-<platform> is used for each version on each platform.")
+	cmd.Flags().StringSliceVar(&flags.Platforms, "platforms", nil,
+		fmt.Sprintf("platforms of the pool matrix to audit the bundles on. If not set, every platform of the "+
+			"matrix is used. [Options: %s]", strings.Join(orchestrate.Platforms, ", ")))
+
+	cmd.Flags().StringVar(&flags.Pool.Name, "pool-name", "ato-cluster-pool",
+		"ClusterPool to claim clusters from. It is created when it does not exist.")
+	cmd.Flags().StringVar(&flags.Pool.Namespace, "namespace", "hive",
+		"OpenShift project (namespace) of the ClusterPool and ClusterClaims.")
+	cmd.Flags().StringVar(&flags.Pool.BaseDomain, "basedomain", "coreostrain.me",
+		"base DNS domain of the clusters of the ClusterPool, which must be managed by the cloud account of the "+
+			"platform.")
+	cmd.Flags().StringVar(&flags.Pool.OpenShift, "openshift", release.LatestVersion,
+		"OpenShift version of the clusters: latest, a minor version such as 4.10 or 4.10.x, or a release such "+
+			"as 4.10.3. The ClusterImageSet of the Hive cluster with the highest matching release is used.")
+	cmd.Flags().StringVar(&flags.Pool.InstallConfig, "install-config", "ato-install-config",
+		"Secret with the install-config.yaml template used to install the clusters of the ClusterPool.")
+	cmd.Flags().StringVar(&flags.Pool.ImagePullSecret, "image-pull-secret", "hive-install-config-global-pullsecret",
+		"Secret with the pull secret used to pull the OpenShift release images when installing the clusters.")
+	cmd.Flags().StringVar(&flags.Pool.Platform, "platform", "",
+		fmt.Sprintf("cloud platform of the clusters of the ClusterPool. If not set, %s is used. [Options: %s]",
+			orchestrate.DefaultPlatform, strings.Join(orchestrate.Platforms, ", ")))
+	cmd.Flags().StringVar(&flags.Pool.Credentials, "credentials", "",
+		"Secret with the cloud credentials of the platform used by Hive to install the clusters.")
+	cmd.Flags().StringVar(&flags.Pool.Region, "region", "",
+		"cloud region of the platform the clusters of the ClusterPool are installed in.")
+	cmd.Flags().Int32Var(&flags.Pool.Running, "running", 0,
+		"number of clusters of the ClusterPool kept running and ready to be claimed; the others are hibernating.")
+	cmd.Flags().Int32Var(&flags.Pool.Size, "size", 0,
+		"number of unclaimed clusters kept installed by the ClusterPool, which sets how many bundles are audited "+
+			"at the same time when --workers is not set.")
+	cmd.Flags().StringVar(&flags.Pool.IBMAccountID, "ibmaccountid", "",
+		"IBM Cloud account ID of the clusters, required when --platform is ibm.")
+	cmd.Flags().StringVar(&flags.Pool.IBMCISInstanceCRN, "ibmcisinstancecrn", "",
+		"CRN of the IBM Cloud Internet Services instance managing the base domain, required when --platform "+
+			"is ibm.")
+	cmd.Flags().BoolVar(&flags.Pool.CreateImageSet, "create-image-set", false,
+		"create the ClusterImageSet of the OpenShift version when the Hive cluster has none, from --release-image "+
+			"or else from the release published on mirror.openshift.com.")
+	cmd.Flags().StringVar(&flags.Pool.ReleaseImage, "release-image", "",
+		"release image of the created ClusterImageSet, which requires --openshift to be a release such as 4.10.3.")
+
+	return cmd
+}
+
+func validation(cmd *cobra.Command, args []string) error {
+	if len(flags.IndexImage) == 0 && len(flags.BundleList) == 0 {
+		return fmt.Errorf("one of the flags --index-image or --bundle-list is required")
+	}
+
+	if len(flags.BundleList) > 0 {
+		if _, err := os.Stat(flags.BundleList); os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if len(flags.JobTemplate) > 0 {
+		if _, err := os.Stat(flags.JobTemplate); os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if flags.JobDeadline < 0 || flags.JobRetries < 0 {
+		return fmt.Errorf("the flags --job-deadline and --job-retries cannot be negative")
+	}
+
+	if flags.UploadArtifacts && (len(flags.BucketName) == 0 || len(flags.ArtifactsDir) == 0) {
+		return fmt.Errorf("the flag --upload-artifacts requires --bucket-name and --artifacts-dir")
+	}
+
+	if len(flags.ContainerEngine) == 0 {
+		flags.ContainerEngine = pkg.GetContainerToolFromEnvVar()
+	}
+
+	if flags.ContainerEngine != pkg.Docker && flags.ContainerEngine != pkg.Podman &&
+		flags.ContainerEngine != pkg.None {
+		return fmt.Errorf("invalid value for the flag --container-engine (%s)."+
+			" The valid options are %s, %s and %s", flags.ContainerEngine, pkg.Docker, pkg.Podman, pkg.None)
+	}
+
+	if !index.IsValidMode(flags.Mode) {
+		return fmt.Errorf("invalid value for the flag --mode (%s). The valid options are %s",
+			flags.Mode, strings.Join(index.Modes, ", "))
+	}
+
+	if err := flags.Filter.Validate(); err != nil {
+		return err
+	}
+
+	if !release.IsValidOpenShiftVersion(flags.Pool.OpenShift) {
+		return fmt.Errorf("invalid value for the flag --openshift (%s). Use latest, a minor version such as "+
+			"4.10 or 4.10.x, or a release such as 4.10.3", flags.Pool.OpenShift)
+	}
+
+	if len(flags.PoolMatrix) > 0 {
+		if _, err := os.Stat(flags.PoolMatrix); os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for _, platform := range flags.Platforms {
+		if !orchestrate.IsValidPlatform(platform) {
+			return fmt.Errorf("invalid value for the flag --platforms (%s). The valid options are %s",
+				platform, strings.Join(orchestrate.Platforms, ", "))
+		}
+	}
+
+	if len(flags.Platforms) > 0 && len(flags.PoolMatrix) == 0 {
+		return fmt.Errorf("the flag --platforms requires --pool-matrix")
+	}
+
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	bundlelist, err := getBundleList()
+	if err != nil {
+		return err
+	}
+
+	targets, err := getTargets(bundlelist)
+	if err != nil {
+		return err
+	}
+
+	store, err := state.Open(flags.StateDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	runFlags, err := json.Marshal(flags)
+	if err != nil {
+		return err
+	}
+
+	// runs fed from a bundle list are recorded with the list as their source
+	source := flags.IndexImage
+	if len(source) == 0 {
+		source = flags.BundleList
+	}
+
+	runID := state.NewRunID()
+	err = store.CreateRun(state.Run{
+		ID:         runID,
+		IndexImage: source,
+		Flags:      string(runFlags),
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+	}, targets)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Run %s started. If interrupted, continue it with `orchestrate resume %s`\n", runID, runID)
+
+	// stop the workers and release their claims on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hvclient, err := orchestrate.GetHiveClient()
+	if err != nil {
+		return err
+	}
+
+	k8sclient, err := orchestrate.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	results, err := orchestrate.AuditRun(ctx, hvclient, k8sclient, store, runID, flags)
+	if printErr := orchestrate.PrintAuditSummary(os.Stdout, results); printErr != nil {
+		log.Errorf("Unable to print the audit summary: %v\n", printErr)
+	}
+
+	if len(flags.JUnit) > 0 {
+		if junitErr := report.WriteRunJUnit(store, runID, flags.JUnit); junitErr != nil {
+			log.Errorf("Unable to write the JUnit report: %v\n", junitErr)
+		}
+	}
+
+	return err
+}
+
+func getBundleList() (index.BundleList, error) {
+	if len(flags.BundleList) > 0 {
+		bundlelist, err := index.ReadBundleList(flags.BundleList)
+		if err != nil {
+			return bundlelist, err
+		}
+
+		return bundlelist.Filter(flags.Filter), nil
+	}
+
+	pkg.CleanupTemporaryDirs()
+	pkg.GenerateTemporaryDirs()
+	defer pkg.CleanupTemporaryDirs()
+
+	bundlelist, err := index.GetDataFromIndexImage(flags.IndexImage, flags.ContainerEngine, flags.Mode)
+	if err != nil {
+		return bundlelist, err
+	}
+
+	return bundlelist.Filter(flags.Filter), nil
+}
+
+// getTargets routes the bundles to the pools of the matrix, or to the pool of the flags when there is no matrix
+func getTargets(bundlelist index.BundleList) ([]state.Target, error) {
+	var targets []state.Target
+	if len(flags.PoolMatrix) == 0 {
+		for _, bundle := range bundlelist.Bundles {
+			targets = append(targets, state.Target{Bundle: bundle})
+		}
+		return targets, nil
+	}
+
+	pools, err := matrix.ReadPools(flags.PoolMatrix, flags.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	flags.Pools = matrix.SelectPools(pools, flags.Platforms)
+	if len(flags.Pools) == 0 {
+		return nil, fmt.Errorf("no pool of the matrix %s is on the platforms %s", flags.PoolMatrix,
+			strings.Join(flags.Platforms, ", "))
+	}
+
+	// bundle lists and indexes without the ClusterServiceVersion annotation have it on the bundle images
+	bundlelist.SetOpenShiftVersionsFromImages()
+
+	return matrix.RouteBundles(bundlelist.Bundles, flags.Pools), nil
+}
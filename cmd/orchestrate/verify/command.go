@@ -1 +1,112 @@
 package verify
+
+// verify the audit results stored in the bucket cover the bundles of the index
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/verify"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"os"
+)
+
+var flags = verify.VerifyFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the audit results in the bucket against the bundles of the index.",
+		Long: "Compare the bundle list produced by `index bundles` with the audit results stored in the bucket by " +
+			"the run and report which bundles have no result, which failed and which succeeded. The command fails " +
+			"when at least one bundle has no result. The bucket is reached with the credentials of " +
+			"--bucket-credentials-secret, --bucket-credentials-file or the MINIO_ENDPOINT, MINIO_ACCESS_KEY_ID " +
+			"and MINIO_SECRET_ACCESS_KEY environment variables.",
+		PreRunE: validation,
+		RunE:    run,
+	}
+
+	cmd.Flags().StringVar(&flags.RunID, "run", "",
+		"run whose audit results are verified.")
+	if err := cmd.MarkFlagRequired("run"); err != nil {
+		log.Fatalf("Failed to mark `run` flag for `verify` sub-command as required")
+	}
+	cmd.Flags().StringVar(&flags.BundleList, "bundle-list", "/tmp/bundlelist.json",
+		"bundle list generated by the `index bundles` sub-command.")
+	cmd.Flags().StringVar(&flags.BucketName, "bucket-name", "",
+		"S3 (minio) compatible bucket where the audit results are stored.")
+	if err := cmd.MarkFlagRequired("bucket-name"); err != nil {
+		log.Fatalf("Failed to mark `bucket-name` flag for `verify` sub-command as required")
+	}
+	cmd.Flags().BoolVar(&flags.Secure, "secure", true,
+		"use https to reach the bucket endpoint.")
+	cmd.Flags().StringVar(&flags.BucketCredentials.File, "bucket-credentials-file", "",
+		fmt.Sprintf("env file with the %s, %s and %s of the bucket, one KEY=value per line. If neither this "+
+			"flag nor --bucket-credentials-secret is set, the environment variables are used.",
+			bucket.EndpointEnvVar, bucket.AccessKeyIDEnvVar, bucket.SecretAccessKeyEnvVar))
+	cmd.Flags().StringVar(&flags.BucketCredentials.Secret, "bucket-credentials-secret", "",
+		"<namespace>/<name> of a Secret on the Hive cluster with the bucket credentials, which takes precedence "+
+			"over --bucket-credentials-file.")
+	cmd.Flags().BoolVar(&flags.RequireSuccess, "require-success", false,
+		"fail also when the audit of a bundle did not succeed.")
+
+	return cmd
+}
+
+func validation(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(flags.BundleList); os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	bundlelist, err := index.ReadBundleList(flags.BundleList)
+	if err != nil {
+		return err
+	}
+
+	var k8sclient kubernetes.Interface
+	if len(flags.BucketCredentials.Secret) > 0 {
+		if k8sclient, err = orchestrate.GetK8sClient(); err != nil {
+			return err
+		}
+	}
+
+	credentials, err := bucket.LoadCredentials(ctx, k8sclient, flags.BucketCredentials)
+	if err != nil {
+		return err
+	}
+
+	client, err := bucket.NewClientFromCredentials(credentials, flags.BucketName, flags.Secure)
+	if err != nil {
+		return err
+	}
+
+	report, err := verify.VerifyBundles(ctx, client, flags.RunID, bundlelist)
+	if err != nil {
+		return err
+	}
+
+	if err := report.Print(os.Stdout); err != nil {
+		return err
+	}
+
+	if report.Missing > 0 {
+		return fmt.Errorf("audit coverage is incomplete: %d of %d bundles have no result",
+			report.Missing, len(report.Bundles))
+	}
+
+	if flags.RequireSuccess && report.Failed > 0 {
+		return fmt.Errorf("audit failed for %d of %d bundles", report.Failed, len(report.Bundles))
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package report
+
+// generate the combined audit report of the bundles of a run
+
+import (
+	"audit-tool-orchestrator/cmd/report/compare"
+	"audit-tool-orchestrator/pkg/report"
+	"audit-tool-orchestrator/pkg/state"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var flags = report.ReportFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate the audit report of the bundles of a run.",
+		Long: "Combine the results recorded for the run in the state database into one report with a row per " +
+			"bundle grouped by package, with the channels of the bundle, the audit outcome, its duration, the " +
+			"version of the cluster it ran on and links to its artifacts. The report is written as " +
+			"report-<run-id>.<ext> in the output path for each format.",
+		PreRunE: validation,
+		RunE:    run,
+	}
+
+	cmd.Flags().StringVar(&flags.RunID, "run", "",
+		"run to report on.")
+	if err := cmd.MarkFlagRequired("run"); err != nil {
+		log.Fatalf("Failed to mark `run` flag for `report` command as required")
+	}
+	cmd.Flags().StringVar(&flags.StateDB, "state-db", state.DefaultDBPath(),
+		"SQLite database recording the runs.")
+	cmd.Flags().StringVar(&flags.OutputPath, "output-path", report.DefaultOutputPath,
+		"directory where the report files are written.")
+	cmd.Flags().StringSliceVar(&flags.OutputFormats, "output-format", report.OutputFormats,
+		fmt.Sprintf("formats of the report. [Options: %s]", strings.Join(report.OutputFormats, ", ")))
+
+	cmd.AddCommand(compare.NewCmd())
+
+	return cmd
+}
+
+func validation(cmd *cobra.Command, args []string) error {
+	for _, format := range flags.OutputFormats {
+		if !report.IsValidOutputFormat(format) {
+			return fmt.Errorf("invalid value for the flag --output-format (%s). The valid options are %s",
+				format, strings.Join(report.OutputFormats, ", "))
+		}
+	}
+
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	store, err := state.Open(flags.StateDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	runReport, err := report.NewReport(store, flags.RunID)
+	if err != nil {
+		return err
+	}
+
+	paths, err := runReport.Write(flags.OutputPath, flags.OutputFormats)
+	for _, path := range paths {
+		log.Infof("Report written to %s\n", path)
+	}
+
+	return err
+}
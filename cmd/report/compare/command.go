@@ -0,0 +1,85 @@
+package compare
+
+// compare the audit outcome of the bundles of two runs
+
+import (
+	"audit-tool-orchestrator/pkg/report"
+	"audit-tool-orchestrator/pkg/state"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var flags = report.CompareFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compare the audit results of two runs.",
+		Long: "Match the bundles of the base and head runs by package and channel, using the bundle with the " +
+			"highest version of each channel, and list the regressions (passed in the base run, failed in the " +
+			"head run), the fixes and the channels added or removed. The command fails when there is at least " +
+			"one regression.",
+		PreRunE: validation,
+		RunE:    run,
+	}
+
+	cmd.Flags().StringVar(&flags.Base, "base", "",
+		"run the head run is compared to.")
+	if err := cmd.MarkFlagRequired("base"); err != nil {
+		log.Fatalf("Failed to mark `base` flag for `compare` sub-command as required")
+	}
+	cmd.Flags().StringVar(&flags.Head, "head", "",
+		"run compared to the base run.")
+	if err := cmd.MarkFlagRequired("head"); err != nil {
+		log.Fatalf("Failed to mark `head` flag for `compare` sub-command as required")
+	}
+	cmd.Flags().StringVar(&flags.StateDB, "state-db", state.DefaultDBPath(),
+		"SQLite database recording the runs.")
+	cmd.Flags().StringVar(&flags.OutputFormat, "output-format", report.Text,
+		fmt.Sprintf("format of the comparison printed to stdout. [Options: %s]",
+			strings.Join(report.CompareOutputFormats, ", ")))
+
+	return cmd
+}
+
+func validation(cmd *cobra.Command, args []string) error {
+	if !report.IsValidCompareOutputFormat(flags.OutputFormat) {
+		return fmt.Errorf("invalid value for the flag --output-format (%s). The valid options are %s",
+			flags.OutputFormat, strings.Join(report.CompareOutputFormats, ", "))
+	}
+
+	return nil
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	store, err := state.Open(flags.StateDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	base, err := report.NewReport(store, flags.Base)
+	if err != nil {
+		return err
+	}
+
+	head, err := report.NewReport(store, flags.Head)
+	if err != nil {
+		return err
+	}
+
+	comparison := report.CompareReports(base, head)
+	if err := comparison.Print(os.Stdout, flags.OutputFormat); err != nil {
+		return err
+	}
+
+	if len(comparison.Regressions) > 0 {
+		return fmt.Errorf("%d bundles regressed from run %s to run %s",
+			len(comparison.Regressions), flags.Base, flags.Head)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package fetch
+
+// download the audit results and artifacts of the bundles of a run from the bucket
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/results"
+	"audit-tool-orchestrator/pkg/state"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"os"
+)
+
+var flags = results.ResultsFlags{}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Download the audit results of the bundles of a run.",
+		Long: "Download the objects stored in the bucket for each bundle of the run, i.e. the audit results and " +
+			"the uploaded artifacts, into <output-dir>/<run-id>/<bundle-name>. The bucket is reached with the " +
+			"credentials of --bucket-credentials-secret, --bucket-credentials-file or the MINIO_ENDPOINT, " +
+			"MINIO_ACCESS_KEY_ID and MINIO_SECRET_ACCESS_KEY environment variables.",
+		RunE: run,
+	}
+
+	cmd.Flags().StringVar(&flags.RunID, "run", "",
+		"run whose results are downloaded.")
+	if err := cmd.MarkFlagRequired("run"); err != nil {
+		log.Fatalf("Failed to mark `run` flag for `fetch` sub-command as required")
+	}
+	cmd.Flags().StringVar(&flags.StateDB, "state-db", state.DefaultDBPath(),
+		"SQLite database recording the runs.")
+	cmd.Flags().StringVar(&flags.BucketName, "bucket-name", "",
+		"S3 (minio) compatible bucket where the results are stored. If not set, the bucket of the run is used.")
+	cmd.Flags().StringVar(&flags.OutputDir, "output-dir", results.DefaultOutputDir,
+		"directory where the results are downloaded.")
+	cmd.Flags().StringSliceVar(&flags.Bundles, "bundle", []string{},
+		"download only the results of these bundles.")
+	cmd.Flags().BoolVar(&flags.Secure, "secure", true,
+		"use https to reach the bucket endpoint.")
+	cmd.Flags().StringVar(&flags.BucketCredentials.File, "bucket-credentials-file", "",
+		fmt.Sprintf("env file with the %s, %s and %s of the bucket, one KEY=value per line. If neither this "+
+			"flag nor --bucket-credentials-secret is set, the environment variables are used.",
+			bucket.EndpointEnvVar, bucket.AccessKeyIDEnvVar, bucket.SecretAccessKeyEnvVar))
+	cmd.Flags().StringVar(&flags.BucketCredentials.Secret, "bucket-credentials-secret", "",
+		"<namespace>/<name> of a Secret on the Hive cluster with the bucket credentials, which takes precedence "+
+			"over --bucket-credentials-file.")
+
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	store, err := state.Open(flags.StateDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	bucketName, err := results.BucketNameForRun(store, flags.RunID, flags.BucketName)
+	if err != nil {
+		return err
+	}
+
+	var k8sclient kubernetes.Interface
+	if len(flags.BucketCredentials.Secret) > 0 {
+		if k8sclient, err = orchestrate.GetK8sClient(); err != nil {
+			return err
+		}
+	}
+
+	credentials, err := bucket.LoadCredentials(ctx, k8sclient, flags.BucketCredentials)
+	if err != nil {
+		return err
+	}
+
+	client, err := bucket.NewClientFromCredentials(credentials, bucketName, flags.Secure)
+	if err != nil {
+		return err
+	}
+
+	fetched, err := results.FetchRun(ctx, client, store, flags.RunID, flags.OutputDir, flags.Bundles)
+	if printErr := results.Print(os.Stdout, fetched); printErr != nil {
+		log.Errorf("Unable to print the fetched results: %v\n", printErr)
+	}
+
+	return err
+}
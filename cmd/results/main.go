@@ -0,0 +1,22 @@
+package results
+
+import (
+	"audit-tool-orchestrator/cmd/results/fetch"
+	"audit-tool-orchestrator/cmd/results/list"
+	"github.com/spf13/cobra"
+)
+
+func NewCmd() *cobra.Command {
+	resultsCmd := &cobra.Command{
+		Use:   "results",
+		Short: "results has subcommands to list and download the audit results stored in the bucket",
+		Long:  "",
+	}
+
+	resultsCmd.AddCommand(
+		fetch.NewCmd(),
+		list.NewCmd(),
+	)
+
+	return resultsCmd
+}
@@ -0,0 +1,224 @@
+package artifacts
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"bufio"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// DefaultDir returns the directory in the user's home directory where the audit artifacts are saved
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return dirName
+	}
+
+	return filepath.Join(home, ".ato", dirName)
+}
+
+// StreamJobLogs writes the container logs of the pods of the Job to out, prefixed with the pod and container
+// names, as they are produced and until the context is done
+func StreamJobLogs(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName string, out io.Writer) {
+	streamed := map[string]bool{}
+
+	_ = wait.PollImmediateUntil(5*time.Second, func() (bool, error) {
+		pods, err := k8sclient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+		})
+		if err != nil {
+			log.Debugf("Unable to list the pods of Job %s: %v\n", jobName, err)
+			return false, nil
+		}
+
+		for _, pod := range pods.Items {
+			for _, container := range pod.Status.ContainerStatuses {
+				key := fmt.Sprintf("%s/%s", pod.Name, container.Name)
+				if streamed[key] || (container.State.Running == nil && container.State.Terminated == nil) {
+					continue
+				}
+
+				streamed[key] = true
+				streamContainerLogs(ctx, k8sclient, pod, container.Name, out)
+			}
+		}
+
+		return false, nil
+	}, ctx.Done())
+}
+
+// streamContainerLogs follows the logs of the container until it stops
+func streamContainerLogs(ctx context.Context, k8sclient kubernetes.Interface, pod corev1.Pod, container string,
+	out io.Writer) {
+	stream, err := k8sclient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Follow:    true,
+	}).Stream(ctx)
+	if err != nil {
+		log.Warnf("Unable to stream the logs of pod %s container %s: %v\n", pod.Name, container, err)
+		return
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		fmt.Fprintf(out, "[%s/%s] %s\n", pod.Name, container, scanner.Text())
+	}
+}
+
+// Collect saves the Job, its pods with their container logs and the events of the namespace as
+// YAML and log files in the directory
+func Collect(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName,
+	dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create the artifacts directory %s : %s", dir, err)
+	}
+
+	job, err := k8sclient.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get Job %s : %s", jobName, err)
+	}
+	job.APIVersion, job.Kind = batchv1.SchemeGroupVersion.String(), jobKind
+	if err := writeArtifact(dir, jobArtifact, job); err != nil {
+		return err
+	}
+
+	pods, err := k8sclient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list the pods of Job %s : %s", jobName, err)
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		pod.APIVersion, pod.Kind = corev1.SchemeGroupVersion.String(), podKind
+		if err := writeArtifact(dir, fmt.Sprintf("pod-%s.yaml", pod.Name), pod); err != nil {
+			return err
+		}
+
+		containers := append(pod.Spec.InitContainers, pod.Spec.Containers...)
+		for _, container := range containers {
+			logs, err := k8sclient.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+			}).DoRaw(ctx)
+			if err != nil {
+				log.Warnf("Unable to get the logs of pod %s container %s: %v\n", pod.Name, container.Name, err)
+				continue
+			}
+
+			logPath := filepath.Join(dir, fmt.Sprintf("%s-%s.log", pod.Name, container.Name))
+			if err := os.WriteFile(logPath, logs, 0644); err != nil {
+				return fmt.Errorf("unable to write %s : %s", logPath, err)
+			}
+		}
+	}
+
+	events, err := k8sclient.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list the events of namespace %s : %s", namespace, err)
+	}
+	events.APIVersion, events.Kind = corev1.SchemeGroupVersion.String(), eventListKind
+
+	return writeArtifact(dir, eventsArtifact, events)
+}
+
+// SaveJob collects the artifacts of the finished Job into the artifacts directory of the bundle and, when
+// flags.Upload is set, uploads them to the bucket under [<run-id>/]<bundle>/artifacts/<job>/
+func SaveJob(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName string,
+	flags Flags) (string, error) {
+	dir := JobDir(flags, jobName)
+	if err := Collect(ctx, k8sclient, namespace, jobName, dir); err != nil {
+		return dir, err
+	}
+
+	if !flags.Upload {
+		return dir, nil
+	}
+
+	client, err := bucket.NewClientFromCredentials(flags.Credentials, flags.BucketName, flags.Secure)
+	if err != nil {
+		return dir, err
+	}
+
+	return dir, client.UploadDir(ctx, dir, ObjectPrefix(flags.RunID, flags.BundleName, jobName))
+}
+
+// Save saves the artifacts of the Job when an artifacts directory is set, logging any failure since it does not
+// change the audit result
+func Save(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName string, flags Flags) {
+	if len(flags.Dir) == 0 {
+		return
+	}
+
+	dir, err := SaveJob(ctx, k8sclient, namespace, jobName, flags)
+	if err != nil {
+		log.Errorf("Unable to save the artifacts of Job %s: %v\n", jobName, err)
+		return
+	}
+
+	log.Infof("Artifacts of Job %s saved in %s\n", jobName, dir)
+}
+
+// Follow streams the logs of the Job to stdout when follow is set. The returned func stops it.
+func Follow(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName string,
+	follow bool) context.CancelFunc {
+	if !follow {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go StreamJobLogs(ctx, k8sclient, namespace, jobName, os.Stdout)
+
+	return cancel
+}
+
+func writeArtifact(dir, name string, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s : %s", name, err)
+	}
+
+	artifactPath := filepath.Join(dir, name)
+	if err := os.WriteFile(artifactPath, data, 0644); err != nil {
+		return fmt.Errorf("unable to write %s : %s", artifactPath, err)
+	}
+
+	return nil
+}
+
+// RunDir returns the directory of the artifacts of the run, or none when artifacts are not saved
+func RunDir(dir, runID string) string {
+	if len(dir) == 0 {
+		return ""
+	}
+
+	return filepath.Join(dir, runID)
+}
+
+// ObjectPrefix returns the prefix of the artifacts of the Job of the bundle in the bucket, under the run ID when
+// the Job is part of a run
+func ObjectPrefix(runID, bundleName, jobName string) string {
+	return path.Join(runID, bundleName, dirName, jobName)
+}
+
+// JobDir returns the local directory of the artifacts of the Job run for the bundle of the flags
+func JobDir(flags Flags, jobName string) string {
+	if len(flags.Dir) == 0 {
+		return ""
+	}
+
+	return filepath.Join(flags.Dir, flags.BundleName, jobName)
+}
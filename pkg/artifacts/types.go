@@ -0,0 +1,18 @@
+package artifacts
+
+import "audit-tool-orchestrator/pkg/bucket"
+
+// Flags tell where the artifacts of the Jobs of a bundle are saved
+type Flags struct {
+	// Dir receives the artifacts under <bundle>/<job>. Nothing is saved when empty.
+	Dir string
+	// RunID prefixes the keys of the uploaded artifacts, when the Jobs are run as part of a run
+	RunID      string
+	BundleName string
+	// Upload also stores the artifacts in the bucket
+	Upload     bool
+	BucketName string
+	Secure     bool
+	// Credentials reach the bucket the artifacts are uploaded to
+	Credentials bucket.Credentials
+}
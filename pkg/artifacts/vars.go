@@ -0,0 +1,10 @@
+package artifacts
+
+// dirName is the directory of the audit artifacts, locally and in the bucket
+const dirName = "artifacts"
+const jobArtifact = "job.yaml"
+const eventsArtifact = "events.yaml"
+
+const jobKind = "Job"
+const podKind = "Pod"
+const eventListKind = "EventList"
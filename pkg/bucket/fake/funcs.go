@@ -0,0 +1,221 @@
+package fake
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewServer starts a Server for the bucket holding the given objects, keyed by their object key. It must be
+// stopped with Close.
+func NewServer(bucketName string, objects map[string][]byte) *Server {
+	s := &Server{Bucket: bucketName, objects: map[string]object{}}
+	for key, data := range objects {
+		s.objects[key] = object{data: data, lastModified: time.Now().UTC()}
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Endpoint returns the http:// endpoint of the server, as set in MINIO_ENDPOINT
+func (s *Server) Endpoint() string {
+	return s.server.URL
+}
+
+// Client returns a bucket client for the bucket of the server
+func (s *Server) Client() (*bucket.Client, error) {
+	return bucket.NewClient(s.Endpoint(), AccessKeyID, SecretAccessKey, s.Bucket, false)
+}
+
+// Object returns the content of the object and whether it exists
+func (s *Server) Object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[key]
+	return obj.data, ok
+}
+
+// Keys returns the sorted keys of the objects in the bucket
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// path style requests: /<bucket>[/<key>]
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.Bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", parts[0], "")
+		return
+	}
+
+	if len(parts) == 1 || len(parts[1]) == 0 {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Has("location"):
+			writeXML(w, struct {
+				XMLName xml.Name `xml:"LocationConstraint"`
+				Region  string   `xml:",chardata"`
+			}{Region: region})
+		case r.Method == http.MethodGet:
+			s.list(w, r)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		default:
+			writeError(w, http.StatusNotImplemented, "NotImplemented", s.Bucket, "")
+		}
+		return
+	}
+
+	key := parts[1]
+	switch r.Method {
+	case http.MethodPut:
+		s.put(w, r, key)
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, key)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", s.Bucket, key)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	result := listBucketResult{Name: s.Bucket, Prefix: prefix, MaxKeys: 1000}
+
+	s.mu.Lock()
+	for key, obj := range s.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		result.Contents = append(result.Contents, objectContent{
+			Key:          key,
+			LastModified: obj.lastModified.Format(lastModifiedFormat),
+			ETag:         etag(obj.data),
+			Size:         int64(len(obj.data)),
+			StorageClass: "STANDARD",
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+	result.KeyCount = len(result.Contents)
+
+	writeXML(w, result)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, key string) {
+	var data []byte
+	var err error
+	if r.Header.Get("X-Amz-Content-Sha256") == streamingPayload {
+		data, err = readChunkedPayload(r.Body)
+	} else {
+		data, err = ioutil.ReadAll(r.Body)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", s.Bucket, key)
+		return
+	}
+
+	s.mu.Lock()
+	s.objects[key] = object{data: data, lastModified: time.Now().UTC()}
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag(data))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	obj, ok := s.objects[key]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey", s.Bucket, key)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	w.Header().Set("ETag", etag(obj.data))
+	w.Header().Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(obj.data)
+	}
+}
+
+// readChunkedPayload returns the data of an aws-chunked body, made of "<hex size>;chunk-signature=<signature>"
+// lines each followed by the chunk and ended by an empty chunk
+func readChunkedPayload(body io.Reader) ([]byte, error) {
+	var data []byte
+	reader := bufio.NewReader(body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("unable to read chunk header : %s", err)
+		}
+
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(header), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk header %q : %s", header, err)
+		}
+
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, fmt.Errorf("unable to read chunk : %s", err)
+		}
+		if size == 0 {
+			return data, nil
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, bucketName, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: code, BucketName: bucketName, Key: key})
+}
@@ -0,0 +1,51 @@
+package fake
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Server is an in-process stand-in for a MinIO (S3) compatible server holding the objects of one bucket in
+// memory. It serves the requests the bucket client sends and does not check their signatures.
+type Server struct {
+	Bucket string
+
+	server  *httptest.Server
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+type object struct {
+	data         []byte
+	lastModified time.Time
+}
+
+// listBucketResult is the response to a ListObjectsV2 request
+type listBucketResult struct {
+	XMLName     xml.Name        `xml:"ListBucketResult"`
+	Name        string          `xml:"Name"`
+	Prefix      string          `xml:"Prefix"`
+	KeyCount    int             `xml:"KeyCount"`
+	MaxKeys     int             `xml:"MaxKeys"`
+	IsTruncated bool            `xml:"IsTruncated"`
+	Contents    []objectContent `xml:"Contents"`
+}
+
+type objectContent struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// errorResponse is the body of the S3 errors returned by the server
+type errorResponse struct {
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName"`
+	Key        string   `xml:"Key"`
+}
@@ -0,0 +1,12 @@
+package fake
+
+// credentials accepted by the server, which ignores them
+const AccessKeyID = "fake-access-key"
+const SecretAccessKey = "fake-secret-key"
+
+const region = "us-east-1"
+
+// streamingPayload is the x-amz-content-sha256 value of uploads sent as signed aws-chunked streams
+const streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+const lastModifiedFormat = "2006-01-02T15:04:05.000Z"
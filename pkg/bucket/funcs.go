@@ -0,0 +1,320 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewClientFromCredentials creates a Client for the bucket using the credentials
+func NewClientFromCredentials(credentials Credentials, bucketName string, secure bool) (*Client, error) {
+	if err := credentials.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewClient(credentials.Endpoint, credentials.AccessKeyID, credentials.SecretAccessKey, bucketName, secure)
+}
+
+// CredentialsFromEnv reads the credentials from the MINIO_* environment variables
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		Endpoint:        os.Getenv(EndpointEnvVar),
+		AccessKeyID:     os.Getenv(AccessKeyIDEnvVar),
+		SecretAccessKey: os.Getenv(SecretAccessKeyEnvVar),
+	}
+}
+
+// CredentialsFromMap reads the credentials from the MINIO_* keys of the map, e.g. the data of a Secret
+func CredentialsFromMap(data map[string]string) Credentials {
+	return Credentials{
+		Endpoint:        data[EndpointEnvVar],
+		AccessKeyID:     data[AccessKeyIDEnvVar],
+		SecretAccessKey: data[SecretAccessKeyEnvVar],
+	}
+}
+
+// CredentialsFromFile reads the credentials from an env file with one MINIO_*=value line per key
+func CredentialsFromFile(path string) (Credentials, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("unable to read the bucket credentials file %s : %s", path, err)
+	}
+
+	data := map[string]string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return Credentials{}, fmt.Errorf("invalid line in the bucket credentials file %s : %q", path, line)
+		}
+		data[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	}
+
+	return CredentialsFromMap(data), nil
+}
+
+// Validate returns an error naming the credentials which are not set
+func (c Credentials) Validate() error {
+	var missing []string
+	for key, value := range c.Map() {
+		if len(value) == 0 {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("bucket credentials %s are missing", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Map returns the credentials keyed by the MINIO_* environment variables of the audit Job
+func (c Credentials) Map() map[string]string {
+	return map[string]string{
+		EndpointEnvVar:        c.Endpoint,
+		AccessKeyIDEnvVar:     c.AccessKeyID,
+		SecretAccessKeyEnvVar: c.SecretAccessKey,
+	}
+}
+
+// IsSet returns whether the credentials are read from a Secret or a file rather than the environment variables
+func (f CredentialsFlags) IsSet() bool {
+	return len(f.Secret) > 0 || len(f.File) > 0
+}
+
+// LoadCredentials returns the credentials of the bucket from the Secret on the Hive cluster, the env file
+// or, when neither is set, the MINIO_* environment variables. It fails when any of them is missing.
+func LoadCredentials(ctx context.Context, k8sclient kubernetes.Interface,
+	flags CredentialsFlags) (Credentials, error) {
+	var credentials Credentials
+	source := "the environment variables"
+
+	switch {
+	case len(flags.Secret) > 0:
+		source = fmt.Sprintf("the Secret %s", flags.Secret)
+		parts := strings.Split(flags.Secret, "/")
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return credentials, fmt.Errorf("invalid bucket credentials Secret %s, expected <namespace>/<name>",
+				flags.Secret)
+		}
+
+		if k8sclient == nil {
+			return credentials, fmt.Errorf("unable to read %s without client for the Hive cluster", source)
+		}
+
+		secret, err := k8sclient.CoreV1().Secrets(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+		if err != nil {
+			return credentials, fmt.Errorf("unable to get the bucket credentials Secret %s : %s", flags.Secret, err)
+		}
+
+		data := map[string]string{}
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		credentials = CredentialsFromMap(data)
+	case len(flags.File) > 0:
+		source = fmt.Sprintf("the file %s", flags.File)
+		var err error
+		credentials, err = CredentialsFromFile(flags.File)
+		if err != nil {
+			return credentials, err
+		}
+	default:
+		credentials = CredentialsFromEnv()
+	}
+
+	if err := credentials.Validate(); err != nil {
+		return credentials, fmt.Errorf("%s in %s. Set them with --bucket-credentials-secret, "+
+			"--bucket-credentials-file or the %s, %s and %s environment variables", err, source,
+			EndpointEnvVar, AccessKeyIDEnvVar, SecretAccessKeyEnvVar)
+	}
+
+	return credentials, nil
+}
+
+// EnsureSecret creates, or updates, the Secret the audit Jobs of the namespace read the bucket credentials from
+func EnsureSecret(ctx context.Context, auditClient kubernetes.Interface, namespace string,
+	credentials Credentials) error {
+	if err := credentials.Validate(); err != nil {
+		return err
+	}
+
+	bucketSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: SecretName,
+		},
+		StringData: credentials.Map(),
+		Type:       "Opaque",
+	}
+
+	secrets := auditClient.CoreV1().Secrets(namespace)
+	_, err := secrets.Create(ctx, &bucketSecret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = secrets.Update(ctx, &bucketSecret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to add bucket credentials secret to cluster under test : %s", err)
+	}
+
+	return nil
+}
+
+// NewClient creates a Client for the bucket served by the S3 (minio) compatible endpoint. An http:// or https://
+// endpoint overrides secure, so a local MinIO compatible server can be used.
+func NewClient(endpoint, accessKeyID, secretAccessKey, bucketName string, secure bool) (*Client, error) {
+	if len(bucketName) == 0 {
+		return nil, fmt.Errorf("bucket name is required")
+	}
+
+	// the scheme of the endpoint, when given, decides whether TLS is used
+	switch {
+	case strings.HasPrefix(endpoint, httpsScheme):
+		endpoint, secure = strings.TrimPrefix(endpoint, httpsScheme), true
+	case strings.HasPrefix(endpoint, httpScheme):
+		endpoint, secure = strings.TrimPrefix(endpoint, httpScheme), false
+	}
+
+	mc, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client for %s : %s", endpoint, err)
+	}
+
+	return &Client{Name: bucketName, minio: mc}, nil
+}
+
+// List returns the objects whose key starts with the prefix sorted from the newest to the oldest
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	for info := range c.minio.ListObjects(ctx, c.Name, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("unable to list objects in bucket %s : %s", c.Name, info.Err)
+		}
+
+		objects = append(objects, Object{
+			Key:          info.Key,
+			Size:         info.Size,
+			LastModified: info.LastModified.UTC().Format(time.RFC3339),
+		})
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].LastModified > objects[j].LastModified
+	})
+
+	return objects, nil
+}
+
+// Get returns the content of the object
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := c.minio.GetObject(ctx, c.Name, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get object %s : %s", key, err)
+	}
+	defer obj.Close()
+
+	data, err := ioutil.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read object %s : %s", key, err)
+	}
+
+	return data, nil
+}
+
+// Put stores the data as the object with the given key
+func (c *Client) Put(ctx context.Context, key string, data []byte) error {
+	_, err := c.minio.PutObject(ctx, c.Name, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to put object %s : %s", key, err)
+	}
+
+	return nil
+}
+
+// UploadDir stores every file of the directory as an object whose key is the prefix and the file relative path
+func (c *Client) UploadDir(ctx context.Context, dir, prefix string) error {
+	return filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := path.Join(prefix, filepath.ToSlash(rel))
+		if _, err := c.minio.FPutObject(ctx, c.Name, key, filePath, minio.PutObjectOptions{}); err != nil {
+			return fmt.Errorf("unable to upload %s to object %s : %s", filePath, key, err)
+		}
+
+		return nil
+	})
+}
+
+// Download saves every object whose key starts with the prefix in the directory, at the key path relative to
+// the prefix, and returns the downloaded objects
+func (c *Client) Download(ctx context.Context, prefix, dir string) ([]Object, error) {
+	objects, err := c.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// every key is checked before anything is written so a bucket holding a key such as ../x is not
+	// partially downloaded
+	filePaths := make([]string, len(objects))
+	for i, object := range objects {
+		filePath, err := downloadPath(dir, prefix, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		filePaths[i] = filePath
+	}
+
+	for i, object := range objects {
+		filePath := filePaths[i]
+		if err := c.minio.FGetObject(ctx, c.Name, object.Key, filePath, minio.GetObjectOptions{}); err != nil {
+			return nil, fmt.Errorf("unable to download object %s to %s : %s", object.Key, filePath, err)
+		}
+	}
+
+	return objects, nil
+}
+
+// downloadPath returns the path in the directory of the object with the key, failing when the key would place
+// it outside of the directory
+func downloadPath(dir, prefix, key string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")))
+	if rel == "." {
+		// the prefix is the whole key
+		rel = path.Base(key)
+	}
+
+	if rel == ".." || filepath.IsAbs(rel) || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("object %s can not be downloaded to %s : its key escapes the directory", key, dir)
+	}
+
+	return filepath.Join(dir, rel), nil
+}
@@ -0,0 +1,266 @@
+package bucket_test
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/bucket/fake"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func newClient(t *testing.T, objects map[string][]byte) (*fake.Server, *bucket.Client) {
+	t.Helper()
+
+	server := fake.NewServer("audit", objects)
+	t.Cleanup(server.Close)
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatalf("unable to create the bucket client: %v", err)
+	}
+
+	return server, client
+}
+
+func objectKeys(objects []bucket.Object) []string {
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestList(t *testing.T) {
+	objects := map[string][]byte{
+		"etcd.v0.9.4/result.json":            []byte(`{}`),
+		"etcd.v0.9.4/scorecard/results.json": []byte(`{"items":[]}`),
+		"etcd.v0.9.2/result.json":            []byte(`{}`),
+		"etcdv2/result.json":                 []byte(`{}`),
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{
+			name:   "every object",
+			prefix: "",
+			want: []string{"etcd.v0.9.2/result.json", "etcd.v0.9.4/result.json",
+				"etcd.v0.9.4/scorecard/results.json", "etcdv2/result.json"},
+		},
+		{
+			name:   "objects of a bundle",
+			prefix: "etcd.v0.9.4/",
+			want:   []string{"etcd.v0.9.4/result.json", "etcd.v0.9.4/scorecard/results.json"},
+		},
+		{
+			name:   "no object",
+			prefix: "memcached",
+		},
+	}
+
+	_, client := newClient(t, objects)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed, err := client.List(context.Background(), tt.prefix)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			if got := objectKeys(listed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %v, want %v", got, tt.want)
+			}
+			for _, object := range listed {
+				if object.Size != int64(len(objects[object.Key])) {
+					t.Errorf("List() size of %s = %d, want %d", object.Key, object.Size, len(objects[object.Key]))
+				}
+			}
+		})
+	}
+}
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		data []byte
+	}{
+		{
+			name: "new object",
+			key:  "etcd.v0.9.4/result.json",
+			data: []byte(`{"bundleName":"etcd.v0.9.4"}`),
+		},
+		{
+			name: "replaced object",
+			key:  "etcd.v0.9.2/result.json",
+			data: []byte(`{"bundleName":"etcd.v0.9.2","status":"Succeeded"}`),
+		},
+		{
+			name: "empty object",
+			key:  "etcd.v0.9.4/empty",
+			data: []byte{},
+		},
+	}
+
+	server, client := newClient(t, map[string][]byte{"etcd.v0.9.2/result.json": []byte(`{}`)})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.Put(context.Background(), tt.key, tt.data); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			got, ok := server.Object(tt.key)
+			if !ok {
+				t.Fatalf("Put() did not store %s", tt.key)
+			}
+			if string(got) != string(tt.data) {
+				t.Errorf("Put() stored %q, want %q", got, tt.data)
+			}
+
+			read, err := client.Get(context.Background(), tt.key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if string(read) != string(tt.data) {
+				t.Errorf("Get() = %q, want %q", read, tt.data)
+			}
+		})
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		prefix string
+		want   []string
+	}{
+		{
+			name:   "nested files",
+			files:  map[string]string{"job.json": "{}", "logs/audit.log": "done", "logs/pods/audit-0.log": "ok"},
+			prefix: "etcd.v0.9.4/artifacts",
+			want: []string{"etcd.v0.9.4/artifacts/job.json", "etcd.v0.9.4/artifacts/logs/audit.log",
+				"etcd.v0.9.4/artifacts/logs/pods/audit-0.log"},
+		},
+		{
+			name:  "no prefix",
+			files: map[string]string{"result.json": "{}"},
+			want:  []string{"result.json"},
+		},
+		{
+			name:  "empty directory",
+			files: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				filePath := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			server, client := newClient(t, nil)
+			if err := client.UploadDir(context.Background(), dir, tt.prefix); err != nil {
+				t.Fatalf("UploadDir() error = %v", err)
+			}
+
+			if got := server.Keys(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UploadDir() uploaded %v, want %v", got, tt.want)
+			}
+			for name, content := range tt.files {
+				key := strings.TrimPrefix(tt.prefix+"/"+name, "/")
+				if got, _ := server.Object(key); string(got) != content {
+					t.Errorf("UploadDir() stored %q as %s, want %q", got, key, content)
+				}
+			}
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects map[string][]byte
+		prefix  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "objects of a bundle",
+			objects: map[string][]byte{
+				"etcd.v0.9.4/result.json":            []byte(`{}`),
+				"etcd.v0.9.4/scorecard/results.json": []byte(`{"items":[]}`),
+				"etcd.v0.9.2/result.json":            []byte(`{"old":true}`),
+			},
+			prefix: "etcd.v0.9.4",
+			want:   map[string]string{"result.json": `{}`, "scorecard/results.json": `{"items":[]}`},
+		},
+		{
+			name:    "whole key as prefix",
+			objects: map[string][]byte{"etcd.v0.9.4/result.json": []byte(`{}`)},
+			prefix:  "etcd.v0.9.4/result.json",
+			want:    map[string]string{"result.json": `{}`},
+		},
+		{
+			name: "key escaping the directory",
+			objects: map[string][]byte{
+				"etcd.v0.9.4/result.json":          []byte(`{}`),
+				"etcd.v0.9.4/../../../escaped.txt": []byte(`outside`),
+			},
+			prefix:  "etcd.v0.9.4",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newClient(t, tt.objects)
+			root := t.TempDir()
+			dir := filepath.Join(root, "a", "b", "out")
+
+			downloaded, err := client.Download(context.Background(), tt.prefix, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := map[string]string{}
+			filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, _ := filepath.Rel(dir, filePath)
+				content, _ := os.ReadFile(filePath)
+				got[filepath.ToSlash(rel)] = string(content)
+				return nil
+			})
+
+			if tt.wantErr {
+				if len(got) > 0 {
+					t.Errorf("Download() wrote %v, want nothing written", got)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Download() wrote %v, want %v", got, tt.want)
+			}
+			if len(downloaded) != len(tt.want) {
+				t.Errorf("Download() returned %d objects, want %d", len(downloaded), len(tt.want))
+			}
+		})
+	}
+}
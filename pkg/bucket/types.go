@@ -0,0 +1,32 @@
+package bucket
+
+import "github.com/minio/minio-go/v7"
+
+// Client reads and writes the audit objects stored in an S3 (minio) compatible bucket
+type Client struct {
+	Name  string
+	minio *minio.Client
+}
+
+// Object describes one object stored in the bucket
+type Object struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"lastModified"`
+}
+
+// Credentials are the endpoint and keys used to reach the bucket, locally and from the audit Job
+type Credentials struct {
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// CredentialsFlags tell where the orchestrator reads the bucket credentials from. The MINIO_* environment
+// variables are used when neither is set.
+type CredentialsFlags struct {
+	// File is an env file with one MINIO_*=value line per key
+	File string `json:"file"`
+	// Secret is the <namespace>/<name> of a Secret with the MINIO_* keys on the Hive cluster
+	Secret string `json:"secret"`
+}
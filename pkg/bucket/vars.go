@@ -0,0 +1,12 @@
+package bucket
+
+// environment variables shared with the audit Job to reach the S3 (minio) compatible bucket
+const EndpointEnvVar = "MINIO_ENDPOINT"
+const AccessKeyIDEnvVar = "MINIO_ACCESS_KEY_ID"
+const SecretAccessKeyEnvVar = "MINIO_SECRET_ACCESS_KEY"
+
+const httpScheme = "http://"
+const httpsScheme = "https://"
+
+// SecretName is the Secret created on the cluster under test with the credentials of the bucket
+const SecretName = "bucket-credentials"
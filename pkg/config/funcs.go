@@ -0,0 +1,506 @@
+package config
+
+import (
+	"audit-tool-orchestrator/pkg"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFile returns the config file read when --config is not set
+func DefaultFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return DefaultFileName
+	}
+
+	return filepath.Join(home, ".ato", DefaultFileName)
+}
+
+// AddConfigFlags adds the --config and --profile flags to the command and its sub-commands
+func AddConfigFlags(cmd *cobra.Command, flags *ConfigFlags) {
+	cmd.PersistentFlags().StringVar(&flags.File, "config", "",
+		fmt.Sprintf("YAML config file with the settings of the commands. Flags set on the command line win over "+
+			"it. If not set, %s is read when it exists.", DefaultFile()))
+	cmd.PersistentFlags().StringVar(&flags.Profile, "profile", "",
+		"profile of the config file applied on top of its shared settings. If not set, the profile named in "+
+			"the file is used.")
+}
+
+// Load reads the config file, rejecting unknown settings
+func Load(path string) (Config, error) {
+	config := Config{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("unable to read the config file %s : %s", path, err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("unable to parse the config file %s : %s", path, err)
+	}
+
+	return config, nil
+}
+
+// Resolve returns the settings of the profile, or of the profile of the file when empty, merged on top of the
+// shared settings, with the name of the profile
+func (c Config) Resolve(profile string) (Settings, string, error) {
+	if len(profile) == 0 {
+		profile = c.Profile
+	}
+
+	if len(profile) == 0 {
+		return c.Settings, profile, nil
+	}
+
+	override, ok := c.Profiles[profile]
+	if !ok {
+		return Settings{}, profile, fmt.Errorf("profile %s not found in the config file. The profiles are %s",
+			profile, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	settings, err := mergeSettings(c.Settings, override)
+	if err != nil {
+		return Settings{}, profile, fmt.Errorf("unable to apply profile %s : %s", profile, err)
+	}
+
+	return settings, profile, nil
+}
+
+// ProfileNames returns the sorted names of the profiles of the file
+func (c Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// mergeSettings sets the values of override on top of base. Lists are replaced, not appended to.
+func mergeSettings(base, override Settings) (Settings, error) {
+	merged := Settings{}
+
+	baseValues, err := settingsMap(base)
+	if err != nil {
+		return merged, err
+	}
+
+	overrideValues, err := settingsMap(override)
+	if err != nil {
+		return merged, err
+	}
+
+	data, err := json.Marshal(mergeMaps(baseValues, overrideValues))
+	if err != nil {
+		return merged, err
+	}
+
+	err = json.Unmarshal(data, &merged)
+
+	return merged, err
+}
+
+// settingsMap returns the settings which are set, as a JSON object
+func settingsMap(settings Settings) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return values, err
+	}
+
+	err = json.Unmarshal(data, &values)
+
+	return values, err
+}
+
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	for key, value := range override {
+		baseValue, baseIsMap := base[key].(map[string]interface{})
+		overrideValue, overrideIsMap := value.(map[string]interface{})
+
+		if baseIsMap && overrideIsMap {
+			base[key] = mergeMaps(baseValue, overrideValue)
+			continue
+		}
+
+		base[key] = value
+	}
+
+	return base
+}
+
+// LoadSettings reads the settings selected by the config flags. It returns false, without error, when no file
+// is set and the default one does not exist.
+func LoadSettings(flags ConfigFlags) (Settings, string, bool, error) {
+	path, err := ConfigFile(flags)
+	if err != nil {
+		if len(flags.Profile) > 0 {
+			return Settings{}, "", false, fmt.Errorf("the flag --profile requires a config file")
+		}
+
+		return Settings{}, "", false, nil
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		return Settings{}, "", false, err
+	}
+
+	settings, profile, err := config.Resolve(flags.Profile)
+	if err != nil {
+		return settings, profile, false, err
+	}
+
+	if err := settings.Validate(); err != nil {
+		if len(profile) > 0 {
+			return settings, profile, false, fmt.Errorf("invalid profile %s of the config file %s : %s",
+				profile, path, err)
+		}
+
+		return settings, profile, false, fmt.Errorf("invalid config file %s : %s", path, err)
+	}
+
+	return settings, profile, true, nil
+}
+
+// Apply sets the flags of the command which were not set on the command line, and the environment variables
+// which are not set, from the settings selected by the config flags
+func Apply(cmd *cobra.Command, flags ConfigFlags) error {
+	settings, _, found, err := LoadSettings(flags)
+	if err != nil || !found {
+		return err
+	}
+
+	for name, value := range settings.Env() {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("unable to set %s from the config file : %s", name, err)
+		}
+	}
+
+	for name, value := range settings.FlagValues(cmd.Name()) {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+
+		// the flag is marked as changed, so that the config file can provide the required flags
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %s for the flag --%s in the config file : %s", value, name, err)
+		}
+	}
+
+	return nil
+}
+
+// Env returns the environment variables set by the settings
+func (s Settings) Env() map[string]string {
+	env := map[string]string{}
+	setString(env, kubeconfigEnv, s.Hive.Kubeconfig)
+	setString(env, registryPullSecretEnv, s.Hive.RegistryPullSecret)
+
+	return env
+}
+
+// FlagValues returns the values of the flags of the command set by the settings, keyed by flag name
+func (s Settings) FlagValues(command string) map[string]string {
+	values := map[string]string{}
+
+	setString(values, "namespace", s.Hive.Namespace)
+
+	setString(values, "pool-name", s.Pool.Name)
+	setString(values, "basedomain", s.Pool.BaseDomain)
+	setString(values, "openshift", s.Pool.OpenShift)
+	setString(values, "install-config", s.Pool.InstallConfig)
+	setString(values, "image-pull-secret", s.Pool.ImagePullSecret)
+	setString(values, "platform", s.Pool.Platform)
+	setString(values, "credentials", s.Pool.Credentials)
+	setString(values, "region", s.Pool.Region)
+	setInt32(values, "running", s.Pool.Running)
+	setInt32(values, "size", s.Pool.Size)
+	setString(values, "ibmaccountid", s.Pool.IBMAccountID)
+	setString(values, "ibmcisinstancecrn", s.Pool.IBMCISInstanceCRN)
+	setBool(values, "create-image-set", s.Pool.CreateImageSet)
+	setString(values, "release-image", s.Pool.ReleaseImage)
+	setString(values, "pool-matrix", s.Pool.Matrix)
+	setStrings(values, "platforms", s.Pool.Platforms)
+
+	setString(values, "job-template", s.Job.Template)
+	setStrings(values, "suites", s.Job.Suites)
+	setBool(values, "parallel", s.Job.Parallel)
+	setString(values, "job-deadline", s.Job.Deadline)
+	setInt(values, "job-retries", s.Job.Retries)
+	setBool(values, "follow-logs", s.Job.FollowLogs)
+	setString(values, "artifacts-dir", s.Job.ArtifactsDir)
+	setBool(values, "upload-artifacts", s.Job.UploadArtifacts)
+
+	setString(values, "bucket-name", s.Bucket.Name)
+	setBool(values, "secure", s.Bucket.Secure)
+	setString(values, "bucket-credentials-file", s.Bucket.CredentialsFile)
+	setString(values, "bucket-credentials-secret", s.Bucket.CredentialsSecret)
+
+	setString(values, "container-engine", s.Index.ContainerEngine)
+	setString(values, "mode", s.Index.Mode)
+	setStrings(values, "include-packages", s.Index.IncludePackages)
+	setStrings(values, "exclude-packages", s.Index.ExcludePackages)
+	setString(values, "label", s.Index.Label)
+	setString(values, "label-value", s.Index.LabelValue)
+	setInt32(values, "limit", s.Index.Limit)
+
+	setString(values, "state-db", s.StateDB)
+
+	// the pool and job commands name some of their flags after themselves
+	switch command {
+	case poolCommand:
+		setString(values, "name", s.Pool.Name)
+	case claimCommand:
+		setString(values, "pool-name", s.Claim.PoolName)
+	case jobCommand:
+		setString(values, "template", s.Job.Template)
+		setString(values, "deadline", s.Job.Deadline)
+		setInt(values, "retries", s.Job.Retries)
+	}
+
+	return values
+}
+
+func setString(values map[string]string, name, value string) {
+	if len(value) > 0 {
+		values[name] = value
+	}
+}
+
+func setStrings(values map[string]string, name string, value []string) {
+	if len(value) > 0 {
+		values[name] = strings.Join(value, ",")
+	}
+}
+
+func setBool(values map[string]string, name string, value *bool) {
+	if value != nil {
+		values[name] = strconv.FormatBool(*value)
+	}
+}
+
+func setInt(values map[string]string, name string, value *int) {
+	if value != nil {
+		values[name] = strconv.Itoa(*value)
+	}
+}
+
+func setInt32(values map[string]string, name string, value *int32) {
+	if value != nil {
+		values[name] = strconv.FormatInt(int64(*value), 10)
+	}
+}
+
+// Validate checks the values of the settings the way the commands check their flags
+func (s Settings) Validate() error {
+	if len(s.Pool.Platform) > 0 && !isOneOf(s.Pool.Platform, orchestrate.Platforms) {
+		return fmt.Errorf("invalid pool platform (%s). The valid options are %s",
+			s.Pool.Platform, strings.Join(orchestrate.Platforms, ", "))
+	}
+
+	for _, platform := range s.Pool.Platforms {
+		if !isOneOf(platform, orchestrate.Platforms) {
+			return fmt.Errorf("invalid pool matrix platform (%s). The valid options are %s",
+				platform, strings.Join(orchestrate.Platforms, ", "))
+		}
+	}
+
+	if (s.Pool.Running != nil && *s.Pool.Running < 0) || (s.Pool.Size != nil && *s.Pool.Size < 0) {
+		return fmt.Errorf("the pool running count and size cannot be negative")
+	}
+
+	if s.Pool.Running != nil && s.Pool.Size != nil && *s.Pool.Running > *s.Pool.Size {
+		return fmt.Errorf("the pool running count (%d) cannot be greater than its size (%d)",
+			*s.Pool.Running, *s.Pool.Size)
+	}
+
+	if len(s.Job.Deadline) > 0 {
+		deadline, err := time.ParseDuration(s.Job.Deadline)
+		if err != nil {
+			return fmt.Errorf("invalid job deadline (%s) : %s", s.Job.Deadline, err)
+		}
+
+		if deadline < 0 {
+			return fmt.Errorf("the job deadline cannot be negative")
+		}
+	}
+
+	if s.Job.Retries != nil && *s.Job.Retries < 0 {
+		return fmt.Errorf("the job retries cannot be negative")
+	}
+
+	files := map[string]string{
+		"hive kubeconfig":           s.Hive.Kubeconfig,
+		"hive registry pull secret": s.Hive.RegistryPullSecret,
+		"job template":              s.Job.Template,
+		"pool matrix":               s.Pool.Matrix,
+		"bucket credentials file":   s.Bucket.CredentialsFile,
+	}
+	for _, setting := range sortedKeys(files) {
+		if len(files[setting]) == 0 {
+			continue
+		}
+
+		if _, err := os.Stat(files[setting]); err != nil {
+			return fmt.Errorf("invalid %s : %s", setting, err)
+		}
+	}
+
+	if len(s.Bucket.CredentialsSecret) > 0 && len(strings.Split(s.Bucket.CredentialsSecret, "/")) != 2 {
+		return fmt.Errorf("invalid bucket credentials secret (%s). It must be <namespace>/<name>",
+			s.Bucket.CredentialsSecret)
+	}
+
+	engines := []string{pkg.Docker, pkg.Podman, pkg.None}
+	if len(s.Index.ContainerEngine) > 0 && !isOneOf(s.Index.ContainerEngine, engines) {
+		return fmt.Errorf("invalid index container engine (%s). The valid options are %s",
+			s.Index.ContainerEngine, strings.Join(engines, ", "))
+	}
+
+	if len(s.Index.Mode) > 0 && !index.IsValidMode(s.Index.Mode) {
+		return fmt.Errorf("invalid index mode (%s). The valid options are %s",
+			s.Index.Mode, strings.Join(index.Modes, ", "))
+	}
+
+	filter := index.BundleFilter{
+		IncludePackages: s.Index.IncludePackages,
+		ExcludePackages: s.Index.ExcludePackages,
+		Label:           s.Index.Label,
+		LabelValue:      s.Index.LabelValue,
+	}
+	if s.Index.Limit != nil {
+		filter.Limit = *s.Index.Limit
+	}
+
+	return filter.Validate()
+}
+
+func isOneOf(value string, options []string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Print writes the settings in YAML
+func (s Settings) Print(out io.Writer) error {
+	values, err := settingsMap(s)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the settings : %s", err)
+	}
+
+	// the sections without any setting are left out
+	for key, value := range values {
+		if section, ok := value.(map[string]interface{}); ok && len(section) == 0 {
+			delete(values, key)
+		}
+	}
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the settings : %s", err)
+	}
+
+	_, err = out.Write(data)
+
+	return err
+}
+
+// GetConfigFlags returns the --config and --profile flags inherited by the command
+func GetConfigFlags(cmd *cobra.Command) (ConfigFlags, error) {
+	flags := ConfigFlags{}
+
+	file, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return flags, err
+	}
+
+	profile, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return flags, err
+	}
+
+	flags.File = file
+	flags.Profile = profile
+
+	return flags, nil
+}
+
+// ConfigFile returns the config file set in the flags or, when it exists, the default one
+func ConfigFile(flags ConfigFlags) (string, error) {
+	if len(flags.File) > 0 {
+		return flags.File, nil
+	}
+
+	if _, err := os.Stat(DefaultFile()); err != nil {
+		return "", fmt.Errorf("no config file: the flag --config is not set and %s does not exist", DefaultFile())
+	}
+
+	return DefaultFile(), nil
+}
+
+// ValidateFile checks the shared settings of the config file and each of its profiles. It returns one error
+// per invalid profile.
+func ValidateFile(path string) []error {
+	config, err := Load(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if err := config.Settings.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid shared settings : %s", err))
+	}
+
+	if len(config.Profile) > 0 {
+		if _, ok := config.Profiles[config.Profile]; !ok {
+			errs = append(errs, fmt.Errorf("the profile of the file (%s) is not one of its profiles", config.Profile))
+		}
+	}
+
+	for _, name := range config.ProfileNames() {
+		settings, _, err := config.Resolve(name)
+		if err == nil {
+			err = settings.Validate()
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid profile %s : %s", name, err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,165 @@
+package config_test
+
+import (
+	"audit-tool-orchestrator/pkg/config"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlagValues(t *testing.T) {
+	retries := 2
+	secure := false
+
+	settings := config.Settings{
+		Hive:   config.HiveSettings{Namespace: "ato-hive", Kubeconfig: "/etc/ato/kubeconfig"},
+		Pool:   config.PoolSettings{Name: "ato-pool", Platforms: []string{"aws", "gcp"}},
+		Claim:  config.ClaimSettings{PoolName: "ato-claimed-pool"},
+		Job:    config.JobSettings{Template: "job.yaml", Deadline: "1h", Retries: &retries},
+		Bucket: config.BucketSettings{Name: "audit", Secure: &secure},
+	}
+
+	shared := map[string]string{
+		"namespace":    "ato-hive",
+		"pool-name":    "ato-pool",
+		"platforms":    "aws,gcp",
+		"job-template": "job.yaml",
+		"job-deadline": "1h",
+		"job-retries":  "2",
+		"bucket-name":  "audit",
+		"secure":       "false",
+	}
+
+	// with returns the shared values with the values of the command
+	with := func(values map[string]string) map[string]string {
+		merged := map[string]string{}
+		for name, value := range shared {
+			merged[name] = value
+		}
+		for name, value := range values {
+			merged[name] = value
+		}
+		return merged
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		settings config.Settings
+		want     map[string]string
+	}{
+		{
+			name:     "no settings",
+			command:  "run",
+			settings: config.Settings{},
+			want:     map[string]string{},
+		},
+		{
+			name:     "run command",
+			command:  "run",
+			settings: settings,
+			want:     shared,
+		},
+		{
+			name:     "pool command names the pool with --name",
+			command:  "pool",
+			settings: settings,
+			want:     with(map[string]string{"name": "ato-pool"}),
+		},
+		{
+			name:     "claim command claims from the pool of the claim settings",
+			command:  "claim",
+			settings: settings,
+			want:     with(map[string]string{"pool-name": "ato-claimed-pool"}),
+		},
+		{
+			name:     "claim command without claim settings",
+			command:  "claim",
+			settings: config.Settings{Pool: config.PoolSettings{Name: "ato-pool"}},
+			want:     map[string]string{"pool-name": "ato-pool"},
+		},
+		{
+			name:     "job command names the template, deadline and retries after itself",
+			command:  "job",
+			settings: settings,
+			want:     with(map[string]string{"template": "job.yaml", "deadline": "1h", "retries": "2"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.FlagValues(tt.command); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlagValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.DefaultFileName)
+	data := []byte(`hive:
+  namespace: ato-hive
+pool:
+  name: ato-pool
+  region: us-east-1
+profile: gcp
+profiles:
+  gcp:
+    pool:
+      region: us-central1
+`)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "config over defaults",
+			want: map[string]string{"namespace": "ato-hive", "pool-name": "ato-pool", "region": "us-central1",
+				"bucket-name": ""},
+		},
+		{
+			name: "flags over config",
+			args: []string{"--namespace", "hive", "--region", "eu-west-1"},
+			want: map[string]string{"namespace": "hive", "pool-name": "ato-pool", "region": "eu-west-1",
+				"bucket-name": ""},
+		},
+		{
+			name:    "unknown profile",
+			profile: "azure",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "run"}
+			cmd.Flags().String("namespace", "hive", "")
+			cmd.Flags().String("pool-name", "ato-cluster-pool", "")
+			cmd.Flags().String("region", "", "")
+			cmd.Flags().String("bucket-name", "", "")
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := config.Apply(cmd, config.ConfigFlags{File: path, Profile: tt.profile})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for name, want := range tt.want {
+				if got, _ := cmd.Flags().GetString(name); got != want {
+					t.Errorf("Apply() --%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
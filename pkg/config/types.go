@@ -0,0 +1,102 @@
+package config
+
+// ConfigFlags select the config file and the profile applied to the commands
+type ConfigFlags struct {
+	// File is the YAML config file. DefaultFile is used when it exists and no file is set.
+	File string `json:"file"`
+	// Profile is applied on top of the settings at the top of the file. The profile of the file when empty.
+	Profile string `json:"profile"`
+}
+
+// Config is the config file: settings shared by every profile and the profiles overriding them
+type Config struct {
+	Settings `json:",inline"`
+	// Profile is the profile used when --profile is not set
+	Profile  string              `json:"profile,omitempty"`
+	Profiles map[string]Settings `json:"profiles,omitempty"`
+}
+
+// Settings hold the values of the flags and environment variables of the commands. Unset values keep the
+// defaults of the commands; flags set on the command line win over them.
+type Settings struct {
+	Hive   HiveSettings   `json:"hive,omitempty"`
+	Pool   PoolSettings   `json:"pool,omitempty"`
+	Claim  ClaimSettings  `json:"claim,omitempty"`
+	Job    JobSettings    `json:"job,omitempty"`
+	Bucket BucketSettings `json:"bucket,omitempty"`
+	Index  IndexSettings  `json:"index,omitempty"`
+	// StateDB is the SQLite database recording the runs
+	StateDB string `json:"stateDB,omitempty"`
+}
+
+// HiveSettings tell how the Hive cluster is reached
+type HiveSettings struct {
+	// Kubeconfig of the Hive cluster, exported as OPENSHIFT_KUBECONFIG unless already set
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Namespace of the ClusterPools and ClusterClaims
+	Namespace string `json:"namespace,omitempty"`
+	// RegistryPullSecret is copied to the claimed clusters, exported as REGISTRY_PULL_SECRET unless already set
+	RegistryPullSecret string `json:"registryPullSecret,omitempty"`
+}
+
+// PoolSettings describe the ClusterPool clusters are claimed from
+type PoolSettings struct {
+	Name              string `json:"name,omitempty"`
+	BaseDomain        string `json:"baseDomain,omitempty"`
+	OpenShift         string `json:"openshift,omitempty"`
+	InstallConfig     string `json:"installConfig,omitempty"`
+	ImagePullSecret   string `json:"imagePullSecret,omitempty"`
+	Platform          string `json:"platform,omitempty"`
+	Credentials       string `json:"credentials,omitempty"`
+	Region            string `json:"region,omitempty"`
+	Running           *int32 `json:"running,omitempty"`
+	Size              *int32 `json:"size,omitempty"`
+	IBMAccountID      string `json:"ibmAccountID,omitempty"`
+	IBMCISInstanceCRN string `json:"ibmCISInstanceCRN,omitempty"`
+	// CreateImageSet creates the ClusterImageSet of the OpenShift version when the Hive cluster has none
+	CreateImageSet *bool  `json:"createImageSet,omitempty"`
+	ReleaseImage   string `json:"releaseImage,omitempty"`
+	// Matrix is the file of the pools, one per OpenShift version and platform, the runs route the bundles to
+	Matrix string `json:"matrix,omitempty"`
+	// Platforms select the pools of the matrix the runs audit on
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// ClaimSettings are the defaults of the ClusterClaims
+type ClaimSettings struct {
+	// PoolName is the ClusterPool claimed from. The pool name when empty.
+	PoolName string `json:"poolName,omitempty"`
+}
+
+// JobSettings are the defaults of the audit Jobs
+type JobSettings struct {
+	Template        string   `json:"template,omitempty"`
+	Suites          []string `json:"suites,omitempty"`
+	Parallel        *bool    `json:"parallel,omitempty"`
+	Deadline        string   `json:"deadline,omitempty"`
+	Retries         *int     `json:"retries,omitempty"`
+	FollowLogs      *bool    `json:"followLogs,omitempty"`
+	ArtifactsDir    string   `json:"artifactsDir,omitempty"`
+	UploadArtifacts *bool    `json:"uploadArtifacts,omitempty"`
+}
+
+// BucketSettings tell where the audit logs are stored
+type BucketSettings struct {
+	Name   string `json:"name,omitempty"`
+	Secure *bool  `json:"secure,omitempty"`
+	// CredentialsFile is an env file with one MINIO_*=value line per key
+	CredentialsFile string `json:"credentialsFile,omitempty"`
+	// CredentialsSecret is the <namespace>/<name> of a Secret with the MINIO_* keys on the Hive cluster
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// IndexSettings tell how the index images are read and which bundles are audited
+type IndexSettings struct {
+	ContainerEngine string   `json:"containerEngine,omitempty"`
+	Mode            string   `json:"mode,omitempty"`
+	IncludePackages []string `json:"includePackages,omitempty"`
+	ExcludePackages []string `json:"excludePackages,omitempty"`
+	Label           string   `json:"label,omitempty"`
+	LabelValue      string   `json:"labelValue,omitempty"`
+	Limit           *int32   `json:"limit,omitempty"`
+}
@@ -0,0 +1,12 @@
+package config
+
+const DefaultFileName = "config.yaml"
+
+// the environment variables read by the orchestrator which can be set from the config file
+const kubeconfigEnv = "OPENSHIFT_KUBECONFIG"
+const registryPullSecretEnv = "REGISTRY_PULL_SECRET"
+
+// the commands whose flags are named differently than in the other commands
+const poolCommand = "pool"
+const claimCommand = "claim"
+const jobCommand = "job"
@@ -0,0 +1,449 @@
+package harness
+
+import (
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/orchestrate/fake"
+	"audit-tool-orchestrator/pkg/release"
+	"context"
+	"fmt"
+	hivev1api "github.com/openshift/hive/apis/hive/v1"
+	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// DefaultDir returns the directory where the harness writes its kubeconfig and pull secret
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return harnessDirName
+	}
+
+	return filepath.Join(home, ".ato", harnessDirName)
+}
+
+// KubeconfigPath returns the path of the kubeconfig of the harness in dir
+func KubeconfigPath(dir string) string {
+	return filepath.Join(dir, kubeconfigFileName)
+}
+
+// PullSecretPath returns the path of the empty registry pull secret of the harness in dir
+func PullSecretPath(dir string) string {
+	return filepath.Join(dir, pullSecretFileName)
+}
+
+// IsValidJobResult returns true when the result can be given to the Jobs by the simulated Job controller
+func IsValidJobResult(result string) bool {
+	for _, valid := range JobResults {
+		if result == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Start runs etcd and kube-apiserver locally, installs the Hive CRDs and creates the namespaces and the
+// ClusterImageSets
+func Start(ctx context.Context, flags HarnessFlags) (*Harness, error) {
+	definitions, err := hiveCRDs()
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envtest.Environment{
+		BinaryAssetsDirectory: flags.AssetsDir,
+		CRDs:                  definitions,
+	}
+
+	if _, err := env.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start the API server : %s", err)
+	}
+
+	h, err := newHarness(env)
+	if err != nil {
+		_ = env.Stop()
+		return nil, err
+	}
+
+	for _, namespace := range flags.Namespaces {
+		if err := ensureNamespace(ctx, h.K8sClient, namespace); err != nil {
+			_ = env.Stop()
+			return nil, err
+		}
+	}
+
+	for _, version := range flags.ImageSets {
+		if err := ensureClusterImageSet(ctx, h.HiveClient, version); err != nil {
+			_ = env.Stop()
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+func newHarness(env *envtest.Environment) (*Harness, error) {
+	user, err := env.AddUser(envtest.User{Name: harnessUser, Groups: []string{mastersGroup}}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to add the user of the harness : %s", err)
+	}
+
+	kubeconfig, err := user.KubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the kubeconfig of the harness : %s", err)
+	}
+
+	hvclient, err := hivev1client.NewForConfig(user.Config())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the Hive client : %s", err)
+	}
+
+	k8sclient, err := kubernetes.NewForConfig(user.Config())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the Kubernetes client : %s", err)
+	}
+
+	return &Harness{
+		env:        env,
+		Kubeconfig: kubeconfig,
+		HiveClient: hvclient,
+		K8sClient:  k8sclient,
+	}, nil
+}
+
+// hiveCRDs reads the embedded Hive CustomResourceDefinitions
+func hiveCRDs() ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	entries, err := crds.ReadDir(crdsDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Hive CRDs : %s", err)
+	}
+
+	var definitions []*apiextensionsv1.CustomResourceDefinition
+	for _, entry := range entries {
+		// embed.FS paths always use forward slashes
+		data, err := crds.ReadFile(path.Join(crdsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the Hive CRD %s : %s", entry.Name(), err)
+		}
+
+		definition := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(data, definition); err != nil {
+			return nil, fmt.Errorf("unable to parse the Hive CRD %s : %s", entry.Name(), err)
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func ensureNamespace(ctx context.Context, k8sclient kubernetes.Interface, name string) error {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+
+	_, err := k8sclient.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create namespace %s : %s", name, err)
+	}
+
+	return nil
+}
+
+// ensureClusterImageSet creates the ClusterImageSet of the OpenShift release, named as the orchestrator names
+// the ones it creates
+func ensureClusterImageSet(ctx context.Context, hvclient hivev1client.Interface, version string) error {
+	imageSet := release.NewClusterImageSet(release.Release{
+		Version: version,
+		Image:   fmt.Sprintf("%s:%s-%s", releaseImageRepository, version, releaseImageArch),
+	})
+
+	_, err := hvclient.HiveV1().ClusterImageSets().Create(ctx, &imageSet, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create ClusterImageSet %s : %s", imageSet.Name, err)
+	}
+
+	return nil
+}
+
+// Stop stops the API server and removes its data
+func (h *Harness) Stop() error {
+	if err := h.env.Stop(); err != nil {
+		return fmt.Errorf("unable to stop the API server : %s", err)
+	}
+
+	return nil
+}
+
+// WriteFiles writes the kubeconfig of the harness and an empty registry pull secret in dir, for the
+// OPENSHIFT_KUBECONFIG and REGISTRY_PULL_SECRET of the orchestrator
+func (h *Harness) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create the harness directory %s : %s", dir, err)
+	}
+
+	if err := os.WriteFile(KubeconfigPath(dir), h.Kubeconfig, 0600); err != nil {
+		return fmt.Errorf("unable to write the harness kubeconfig : %s", err)
+	}
+
+	if err := os.WriteFile(PullSecretPath(dir), []byte(emptyPullSecret), 0600); err != nil {
+		return fmt.Errorf("unable to write the harness pull secret : %s", err)
+	}
+
+	return nil
+}
+
+// NewController returns the simulated controllers of the clients. The ClusterClaims are given clusters reached
+// with kubeconfig.
+func NewController(hvclient hivev1client.Interface, k8sclient kubernetes.Interface, kubeconfig []byte,
+	flags ControllerFlags) *Controller {
+	return &Controller{
+		hvclient:   hvclient,
+		k8sclient:  k8sclient,
+		kubeconfig: kubeconfig,
+		flags:      flags,
+	}
+}
+
+// Run reconciles the resources every interval until the context is done
+func (c *Controller) Run(ctx context.Context) {
+	wait.UntilWithContext(ctx, c.Reconcile, c.flags.Interval)
+}
+
+// Reconcile brings the ClusterPools, ClusterClaims and Jobs one step closer to what Hive and the Job controller
+// would make of them
+func (c *Controller) Reconcile(ctx context.Context) {
+	if err := c.reconcileClusterPools(ctx); err != nil {
+		log.Warnf("Unable to reconcile the ClusterPools: %v\n", err)
+	}
+
+	if err := c.reconcileClusterClaims(ctx); err != nil {
+		log.Warnf("Unable to reconcile the ClusterClaims: %v\n", err)
+	}
+
+	if err := c.reconcileClusterDeployments(ctx); err != nil {
+		log.Warnf("Unable to reconcile the ClusterDeployments: %v\n", err)
+	}
+
+	if err := c.reconcileJobs(ctx); err != nil {
+		log.Warnf("Unable to reconcile the Jobs: %v\n", err)
+	}
+}
+
+// reconcileClusterPools reports every cluster of the ClusterPools as provisioned and ready
+func (c *Controller) reconcileClusterPools(ctx context.Context) error {
+	pools, err := c.hvclient.HiveV1().ClusterPools(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, pool := range pools.Items {
+		if pool.Status.Ready == pool.Spec.RunningCount && pool.Status.Size == pool.Spec.Size {
+			continue
+		}
+
+		if err := fake.SetClusterPoolReady(ctx, c.hvclient, pool.Namespace, pool.Name); err != nil {
+			return err
+		}
+		log.Infof("ClusterPool %s/%s is ready.\n", pool.Namespace, pool.Name)
+	}
+
+	return nil
+}
+
+// reconcileClusterClaims creates a ClusterDeployment, with its admin kubeconfig Secret, for each ClusterClaim
+// waiting for a cluster and assigns it to the claim
+func (c *Controller) reconcileClusterClaims(ctx context.Context) error {
+	claims, err := c.hvclient.HiveV1().ClusterClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, claim := range claims.Items {
+		if len(claim.Spec.Namespace) > 0 || claim.DeletionTimestamp != nil {
+			continue
+		}
+
+		pool, err := c.hvclient.HiveV1().ClusterPools(claim.Namespace).Get(ctx, claim.Spec.ClusterPoolName,
+			metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+
+			if err := fake.SetClusterClaimPending(ctx, c.hvclient, claim.Namespace, claim.Name,
+				"ClusterPoolNotFound"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cdNamespace, err := c.createClusterDeployment(ctx, pool, claim.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := fake.SetClusterClaimRunning(ctx, c.hvclient, claim.Namespace, claim.Name, cdNamespace); err != nil {
+			return err
+		}
+		log.Infof("ClusterClaim %s/%s is fulfilled by ClusterDeployment %s.\n", claim.Namespace, claim.Name,
+			cdNamespace)
+	}
+
+	return nil
+}
+
+// createClusterDeployment creates the namespace, admin kubeconfig Secret and ClusterDeployment of a cluster of
+// the pool claimed by the ClusterClaim and returns the namespace
+func (c *Controller) createClusterDeployment(ctx context.Context, pool *hivev1api.ClusterPool,
+	claimName string) (string, error) {
+	name := fmt.Sprintf("%s-%s", pool.Name, rand.String(5))
+	if err := ensureNamespace(ctx, c.k8sclient, name); err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + kubeconfigSecretSuffix,
+			Namespace: name,
+		},
+		Data: map[string][]byte{
+			"kubeconfig":     c.kubeconfig,
+			"raw-kubeconfig": c.kubeconfig,
+		},
+	}
+
+	if _, err := c.k8sclient.CoreV1().Secrets(name).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("unable to create the admin kubeconfig of ClusterDeployment %s : %s", name, err)
+	}
+
+	clusterDeployment := &hivev1api.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: name,
+			Labels:    map[string]string{clusterVersionLabel: c.clusterVersion(ctx, pool)},
+		},
+		Spec: hivev1api.ClusterDeploymentSpec{
+			ClusterName: name,
+			BaseDomain:  pool.Spec.BaseDomain,
+			Platform:    pool.Spec.Platform,
+			ClusterMetadata: &hivev1api.ClusterMetadata{
+				ClusterID:                name,
+				InfraID:                  name,
+				AdminKubeconfigSecretRef: corev1.LocalObjectReference{Name: secret.Name},
+			},
+			ClusterPoolRef: &hivev1api.ClusterPoolReference{
+				Namespace: pool.Namespace,
+				PoolName:  pool.Name,
+				ClaimName: claimName,
+			},
+			Installed: true,
+		},
+	}
+
+	_, err := c.hvclient.HiveV1().ClusterDeployments(name).Create(ctx, clusterDeployment, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to create ClusterDeployment %s : %s", name, err)
+	}
+
+	return name, nil
+}
+
+// clusterVersion returns the OpenShift version of the ClusterImageSet of the pool, or the version of the flags
+// when it has none
+func (c *Controller) clusterVersion(ctx context.Context, pool *hivev1api.ClusterPool) string {
+	imageSet, err := c.hvclient.HiveV1().ClusterImageSets().Get(ctx, pool.Spec.ImageSetRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return c.flags.ClusterVersion
+	}
+
+	if version, ok := release.ClusterImageSetVersion(*imageSet); ok {
+		return version
+	}
+
+	return c.flags.ClusterVersion
+}
+
+// reconcileClusterDeployments removes the clusters whose ClusterClaim was deleted
+func (c *Controller) reconcileClusterDeployments(ctx context.Context) error {
+	clusterDeployments, err := c.hvclient.HiveV1().ClusterDeployments(metav1.NamespaceAll).List(ctx,
+		metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, clusterDeployment := range clusterDeployments.Items {
+		poolRef := clusterDeployment.Spec.ClusterPoolRef
+		if poolRef == nil || len(poolRef.ClaimName) == 0 || clusterDeployment.DeletionTimestamp != nil {
+			continue
+		}
+
+		_, err := c.hvclient.HiveV1().ClusterClaims(poolRef.Namespace).Get(ctx, poolRef.ClaimName, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		// without a namespace controller the namespace lingers, the ClusterDeployment is deleted on its own
+		err = c.hvclient.HiveV1().ClusterDeployments(clusterDeployment.Namespace).Delete(ctx, clusterDeployment.Name,
+			metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		err = c.k8sclient.CoreV1().Namespaces().Delete(ctx, clusterDeployment.Namespace, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.Infof("ClusterDeployment %s of the deleted ClusterClaim %s/%s is removed.\n", clusterDeployment.Name,
+			poolRef.Namespace, poolRef.ClaimName)
+	}
+
+	return nil
+}
+
+// reconcileJobs finishes the Jobs which ran for the Job duration with the Job result
+func (c *Controller) reconcileJobs(ctx context.Context) error {
+	jobs, err := c.k8sclient.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs.Items {
+		if _, finished := orchestrate.JobFinalStatus(&job); finished {
+			continue
+		}
+
+		if time.Since(job.CreationTimestamp.Time) < c.flags.JobDuration {
+			continue
+		}
+
+		if c.flags.JobResult == string(batchv1.JobFailed) {
+			err = fake.FailJob(ctx, c.k8sclient, job.Namespace, job.Name, jobFailedReason, jobFailedMessage)
+		} else {
+			err = fake.CompleteJob(ctx, c.k8sclient, job.Namespace, job.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+		log.Infof("Job %s/%s is finished: %s.\n", job.Namespace, job.Name, c.flags.JobResult)
+	}
+
+	return nil
+}
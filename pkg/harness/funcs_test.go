@@ -0,0 +1,144 @@
+package harness_test
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/harness"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"context"
+	batchv1 "k8s.io/api/batch/v1"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// assetsDir returns the directory of the etcd and kube-apiserver binaries, skipping the test when they are absent
+func assetsDir(t *testing.T) string {
+	t.Helper()
+
+	dir := os.Getenv("KUBEBUILDER_ASSETS")
+	if len(dir) == 0 {
+		t.Skip("KUBEBUILDER_ASSETS is not set, the envtest assets are required to start the harness")
+	}
+
+	for _, binary := range []string{"etcd", "kube-apiserver"} {
+		if _, err := os.Stat(filepath.Join(dir, binary)); err != nil {
+			t.Skipf("the envtest asset %s is absent from %s", binary, dir)
+		}
+	}
+
+	return dir
+}
+
+func TestHarness(t *testing.T) {
+	dir := assetsDir(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	h, err := harness.Start(ctx, harness.HarnessFlags{
+		AssetsDir:  dir,
+		Namespaces: harness.DefaultNamespaces,
+		ImageSets:  harness.DefaultImageSets,
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer h.Stop()
+
+	filesDir := t.TempDir()
+	if err := h.WriteFiles(filesDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REGISTRY_PULL_SECRET", harness.PullSecretPath(filesDir))
+
+	tests := []struct {
+		name      string
+		pool      string
+		jobResult string
+		want      batchv1.JobConditionType
+		wantErr   bool
+	}{
+		{
+			name:      "audit Job complete",
+			pool:      "ato-pool-complete",
+			jobResult: string(batchv1.JobComplete),
+			want:      batchv1.JobComplete,
+		},
+		{
+			name:      "audit Job failed",
+			pool:      "ato-pool-failed",
+			jobResult: string(batchv1.JobFailed),
+			want:      batchv1.JobFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			controller := harness.NewController(h.HiveClient, h.K8sClient, h.Kubeconfig, harness.ControllerFlags{
+				Interval:       100 * time.Millisecond,
+				ClusterVersion: harness.DefaultClusterVersion,
+				JobResult:      tt.jobResult,
+			})
+			go controller.Run(ctx)
+
+			pool, err := orchestrate.EnsureClusterPool(ctx, h.HiveClient, orchestrate.PoolFlags{
+				Name:            tt.pool,
+				Namespace:       "hive",
+				BaseDomain:      "example.com",
+				OpenShift:       "4.10",
+				InstallConfig:   "install-config",
+				ImagePullSecret: "pull-secret",
+				Platform:        "aws",
+				Credentials:     "aws-credentials",
+				Region:          "us-east-1",
+				Running:         1,
+				Size:            1,
+			})
+			if err != nil {
+				t.Fatalf("EnsureClusterPool() error = %v", err)
+			}
+
+			cdNamespace, err := orchestrate.ClaimClusterForBundle(ctx, h.HiveClient, orchestrate.ClaimFlags{
+				Name:       pool.Name + "-claim",
+				Namespace:  pool.Namespace,
+				PoolName:   pool.Name,
+				BundleName: "etcd.v0.9.4",
+			})
+			if err != nil {
+				t.Fatalf("ClaimClusterForBundle() error = %v", err)
+			}
+
+			kubeconfig, err := orchestrate.GetClusterUnderTestKubeconfig(ctx, h.HiveClient, h.K8sClient, cdNamespace)
+			if err != nil {
+				t.Fatalf("GetClusterUnderTestKubeconfig() error = %v", err)
+			}
+
+			auditClient, err := orchestrate.K8sClientForAudit(kubeconfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := orchestrate.PrepareClusterUnderTest(ctx, auditClient, orchestrate.DefaultJobNamespace,
+				kubeconfig, bucket.Credentials{}); err != nil {
+				t.Fatalf("PrepareClusterUnderTest() error = %v", err)
+			}
+
+			got, err := orchestrate.RunAuditJob(ctx, auditClient, orchestrate.JobFlags{
+				Name:        pool.Name + "-audit",
+				BundleImage: "quay.io/operatorhubio/etcd:v0.9.4",
+				BundleName:  "etcd.v0.9.4",
+				ClaimName:   pool.Name + "-claim",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunAuditJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Result != tt.want || got.Infrastructure {
+				t.Errorf("RunAuditJob() = %+v, want result %s", got, tt.want)
+			}
+		})
+	}
+}
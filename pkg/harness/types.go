@@ -0,0 +1,51 @@
+package harness
+
+import (
+	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"time"
+)
+
+type HarnessFlags struct {
+	// AssetsDir holds the etcd and kube-apiserver binaries. KUBEBUILDER_ASSETS is used when empty.
+	AssetsDir string `json:"assetsDir"`
+	// Dir receives the kubeconfig of the API server and an empty registry pull secret
+	Dir        string   `json:"dir"`
+	Namespaces []string `json:"namespaces"`
+	// ImageSets are the OpenShift releases of the ClusterImageSets created at start
+	ImageSets  []string        `json:"imageSets"`
+	Controller ControllerFlags `json:"controller"`
+}
+
+// ControllerFlags tune how the simulated controllers fulfil the resources
+type ControllerFlags struct {
+	// Interval is the time between two reconciliations of the resources
+	Interval time.Duration `json:"interval"`
+	// ClusterVersion is reported by the ClusterDeployments created for the ClusterClaims, unless the ClusterImageSet
+	// of their pool has a version
+	ClusterVersion string `json:"clusterVersion"`
+	// JobDuration is the time the Jobs run before they are finished
+	JobDuration time.Duration `json:"jobDuration"`
+	// JobResult is the condition set on the finished Jobs, Complete or Failed
+	JobResult string `json:"jobResult"`
+}
+
+// Harness is a local API server serving the Hive resources, which stands for both the Hive cluster and the
+// claimed clusters
+type Harness struct {
+	env        *envtest.Environment
+	Kubeconfig []byte
+	HiveClient hivev1client.Interface
+	K8sClient  kubernetes.Interface
+}
+
+// Controller simulates the Hive and Job controllers: it marks the ClusterPools ready, fulfils the ClusterClaims
+// and finishes the Jobs
+type Controller struct {
+	hvclient  hivev1client.Interface
+	k8sclient kubernetes.Interface
+	// kubeconfig is the admin kubeconfig of every cluster handed to the ClusterClaims
+	kubeconfig []byte
+	flags      ControllerFlags
+}
@@ -0,0 +1,46 @@
+package harness
+
+import (
+	"embed"
+	"time"
+)
+
+// crds are the Hive CustomResourceDefinitions used by the orchestrator
+//
+//go:embed crds/*.yaml
+var crds embed.FS
+
+const crdsDir = "crds"
+
+const harnessDirName = "harness"
+const kubeconfigFileName = "kubeconfig"
+
+// pullSecretFileName receives an empty registry pull secret, for the REGISTRY_PULL_SECRET of the orchestrator
+const pullSecretFileName = "pull-secret.json"
+const emptyPullSecret = `{"auths":{}}`
+
+const DefaultInterval = 2 * time.Second
+const DefaultJobDuration = 10 * time.Second
+const DefaultClusterVersion = "4.10.3"
+
+// releaseImageRepository is the repository of the release images of the ClusterImageSets created at start
+const releaseImageRepository = "quay.io/openshift-release-dev/ocp-release"
+const releaseImageArch = "x86_64"
+
+// harnessUser is the user of the kubeconfig written by the harness, with all the permissions on the API server
+const harnessUser = "ato-harness"
+const mastersGroup = "system:masters"
+
+const kubeconfigSecretSuffix = "-admin-kubeconfig"
+
+// clusterVersionLabel is set by Hive on the ClusterDeployments with the installed OpenShift version
+const clusterVersionLabel = "hive.openshift.io/version-major-minor-patch"
+const jobFailedReason = "BackoffLimitExceeded"
+const jobFailedMessage = "Job has reached the specified backoff limit"
+
+// JobResults are the outcomes the simulated Job controller can give to the Jobs
+var JobResults = []string{"Complete", "Failed"}
+
+var DefaultNamespaces = []string{"hive"}
+
+var DefaultImageSets = []string{DefaultClusterVersion}
@@ -1,17 +1,37 @@
 package index
 
 import (
+	"archive/tar"
 	. "audit-tool-orchestrator/pkg"
 	"bytes"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	sq "github.com/Masterminds/squirrel"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	_ "github.com/mattn/go-sqlite3"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
 	"io/ioutil"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	sigsyaml "sigs.k8s.io/yaml"
+	"sort"
 	"strings"
+	"text/tabwriter"
 )
 
 func DownloadImage(image string, containerEngine string) error {
@@ -50,22 +70,475 @@ func ExtractIndexDB(image string, containerEngine string) error {
 	return nil
 }
 
-func BuildBundlesQuery() (string, error) {
-	query := sq.Select("o.name, o.bundlepath").From(
-		"operatorbundle o, channel c").Distinct()
+// GetConfigsDir returns the directory of the file-based catalog shipped in the image
+// or an empty string when the index image ships a SQLite database
+func GetConfigsDir(image string, containerEngine string) (string, error) {
+	command := exec.Command(containerEngine, "inspect", "--format",
+		fmt.Sprintf("{{ index .Config.Labels %q }}", ConfigsLabel), image)
+	output, err := RunCommand(command)
+	if err != nil {
+		return "", fmt.Errorf("unable to inspect the image %s : %s", image, err)
+	}
+
+	configsDir := strings.TrimSpace(string(output))
+	if configsDir == "<no value>" {
+		return "", nil
+	}
+
+	return configsDir, nil
+}
+
+// ExtractIndexConfigs copies the file-based catalog directory of the image to the output directory
+func ExtractIndexConfigs(image string, containerEngine string, configsDir string) error {
+	log.Info("Extracting file-based catalog...")
+	// Remove image if exists already
+	command := exec.Command(containerEngine, "rm", catalogIndex)
+	_, _ = RunCommand(command)
+
+	// Download the image
+	command = exec.Command(containerEngine, "create", "--name", catalogIndex, image, "\"yes\"")
+	_, err := RunCommand(command)
+	if err != nil {
+		return fmt.Errorf("unable to create container image %s : %s", image, err)
+	}
+
+	// Extract
+	command = exec.Command(containerEngine, "cp", fmt.Sprintf("%s:%s", catalogIndex, configsDir), configsOutputDir)
+	_, err = RunCommand(command)
+	if err != nil {
+		return fmt.Errorf("unable to extract the image for %s %s : %s", configsDir, image, err)
+	}
+	return nil
+}
+
+// ExtractIndex downloads the index image and extracts either its SQLite database or its file-based catalog
+// to the output directory. It returns the file-based catalog directory of the image, empty for SQLite indexes.
+func ExtractIndex(image string, containerEngine string) (string, error) {
+	// a previous extraction would be mixed with this one
+	_ = os.RemoveAll(configsOutputDir)
+	_ = os.Remove(indexDBOutputPath)
+
+	if containerEngine == None || IsLocalImage(image) {
+		return extractIndexFromImageLayers(image)
+	}
+
+	if err := DownloadImage(image, containerEngine); err != nil {
+		return "", err
+	}
+
+	configsDir, err := GetConfigsDir(image, containerEngine)
+	if err != nil {
+		return "", err
+	}
+
+	if len(configsDir) > 0 {
+		log.Infof("Index image %s is a file-based catalog (%s)", image, configsDir)
+		return configsDir, ExtractIndexConfigs(image, containerEngine, configsDir)
+	}
+
+	return "", ExtractIndexDB(image, containerEngine)
+}
+
+// GetDataFromIndexImage downloads the index image and lists its bundles for the mode,
+// reading either the SQLite database or the file-based catalog shipped in the image
+func GetDataFromIndexImage(image string, containerEngine string, mode string) (BundleList, error) {
+	configsDir, err := ExtractIndex(image, containerEngine)
+	if err != nil {
+		return BundleList{}, err
+	}
+
+	if len(configsDir) > 0 {
+		return GetDataFromIndexConfigs(BundleList{}, configsOutputDir, mode)
+	}
+
+	return GetDataFromIndexDB(BundleList{}, mode)
+}
+
+// GetCatalogFromIndexImage downloads the index image and reads all its packages, channels and bundles
+func GetCatalogFromIndexImage(image string, containerEngine string) (Catalog, error) {
+	configsDir, err := ExtractIndex(image, containerEngine)
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	if len(configsDir) > 0 {
+		return getCatalogFromIndexConfigs(configsOutputDir)
+	}
+
+	return getCatalogFromIndexDB(indexDBOutputPath)
+}
+
+// IsLocalImage reports whether the image is an OCI layout directory or a docker save tarball
+func IsLocalImage(image string) bool {
+	return strings.HasPrefix(image, OCILayoutTransport) || strings.HasPrefix(image, DockerArchiveTransport)
+}
+
+// LoadImage reads the image from the registry, using the credentials of the docker config, or from
+// the local filesystem when the image is prefixed with oci: (OCI layout directory) or docker-archive:
+// (docker save tarball)
+func LoadImage(image string) (v1.Image, error) {
+	switch {
+	case strings.HasPrefix(image, OCILayoutTransport):
+		path, err := layout.FromPath(strings.TrimPrefix(image, OCILayoutTransport))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the OCI layout %s : %s", image, err)
+		}
+
+		imageIndex, err := path.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the OCI layout index %s : %s", image, err)
+		}
+
+		images, err := indexImages(imageIndex)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the OCI layout index %s : %s", image, err)
+		}
 
-	query = query.Where("c.head_operatorbundle_name == o.name")
+		if len(images) == 0 {
+			return nil, fmt.Errorf("no image found in the OCI layout %s", image)
+		}
+
+		// the index content is the same on every platform, the first image is taken when none is for the
+		// default one
+		selected := images[0]
+		for _, img := range images {
+			if img.descriptor.Platform != nil && img.descriptor.Platform.Equals(defaultPlatform) {
+				selected = img
+				break
+			}
+		}
+
+		return selected.index.Image(selected.descriptor.Digest)
+	case strings.HasPrefix(image, DockerArchiveTransport):
+		img, err := tarball.ImageFromPath(strings.TrimPrefix(image, DockerArchiveTransport), nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the docker archive %s : %s", image, err)
+		}
+
+		return img, nil
+	}
+
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %s : %s", image, err)
+	}
+
+	log.Infof("Fetching image %s from the registry...", image)
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the image %s : %s", image, err)
+	}
+
+	return img, nil
+}
+
+// indexImages returns the image manifests of the index, resolving the nested indexes such as the one of a
+// multi-arch image in an OCI layout
+func indexImages(imageIndex v1.ImageIndex) ([]indexImage, error) {
+	manifest, err := imageIndex.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []indexImage
+	for _, descriptor := range manifest.Manifests {
+		switch {
+		case descriptor.MediaType.IsImage():
+			images = append(images, indexImage{index: imageIndex, descriptor: descriptor})
+		case descriptor.MediaType.IsIndex():
+			nested, err := imageIndex.ImageIndex(descriptor.Digest)
+			if err != nil {
+				return nil, err
+			}
+
+			nestedImages, err := indexImages(nested)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, nestedImages...)
+		}
+	}
+
+	return images, nil
+}
+
+// ExtractIndexFromImage writes the SQLite database or the file-based catalog directory found in the layers
+// of the image to the output directory. It returns the file-based catalog directory when there is one.
+func ExtractIndexFromImage(img v1.Image) (string, error) {
+	config, err := img.ConfigFile()
+	if err != nil {
+		return "", fmt.Errorf("unable to read the image config : %s", err)
+	}
+
+	configsDir := strings.Trim(config.Config.Labels[ConfigsLabel], "/")
+
+	// the flattened filesystem already applies the whiteouts of the upper layers
+	fs := mutate.Extract(img)
+	defer fs.Close()
+
+	found := false
+	reader := tar.NewReader(fs)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to read the image layers : %s", err)
+		}
 
-	//query := sq.Select("o.name, o.bundlepath").From(
-	//	"operatorbundle o")
+		entry := strings.TrimPrefix(filepath.Clean("/"+header.Name), "/")
+
+		var target string
+		switch {
+		case len(configsDir) > 0 && strings.HasPrefix(entry, configsDir+"/"):
+			target = filepath.Join(configsOutputDir, strings.TrimPrefix(entry, configsDir+"/"))
+		case len(configsDir) == 0 && entry == indexDBPath:
+			target = indexDBOutputPath
+		default:
+			continue
+		}
 
-	query.OrderBy("o.name")
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := writeFile(target, reader); err != nil {
+			return "", err
+		}
+		found = true
+	}
+
+	if !found && len(configsDir) > 0 {
+		return "", fmt.Errorf("file-based catalog %s not found in the image", configsDir)
+	}
+	if !found {
+		return "", fmt.Errorf("%s not found in the image", indexDBPath)
+	}
 
-	sql, _, err := query.ToSql()
+	return configsDir, nil
+}
+
+func extractIndexFromImageLayers(image string) (string, error) {
+	img, err := LoadImage(image)
 	if err != nil {
-		return "", fmt.Errorf("unable to create sql : %s", err)
+		return "", err
+	}
+
+	log.Info("Extracting index from the image layers...")
+	configsDir, err := ExtractIndexFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("unable to extract the index from %s : %s", image, err)
+	}
+
+	if len(configsDir) > 0 {
+		log.Infof("Index image %s is a file-based catalog (%s)", image, configsDir)
 	}
-	return sql, nil
+
+	return configsDir, nil
+}
+
+func writeFile(path string, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("unable to create directory for %s : %s", path, err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create %s : %s", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, content); err != nil {
+		return fmt.Errorf("unable to write %s : %s", path, err)
+	}
+
+	return nil
+}
+
+// BuildBundlesQuery returns the query, and its arguments, selecting the bundles of the index for the options
+// together with their channel entries and the default channel of their package
+func BuildBundlesQuery(opts BundlesOptions) (string, []interface{}, error) {
+	query := sq.Select("o.name", "o.bundlepath", "o.version", "o.skiprange", "o.replaces", "o.skips", "o.csv",
+		"e.channel_name", "e.package_name", "p.default_channel").
+		Distinct().
+		From("operatorbundle o").
+		LeftJoin("channel_entry e ON e.operatorbundle_name = o.name").
+		LeftJoin("package p ON p.name = e.package_name")
+
+	switch opts.Mode {
+	case HeadsMode, "":
+		query = query.Where("o.name IN (SELECT c.head_operatorbundle_name FROM channel c)")
+	case DefaultChannelHeadsMode:
+		query = query.Where("o.name IN (SELECT c.head_operatorbundle_name FROM channel c " +
+			"JOIN package dp ON dp.name = c.package_name AND dp.default_channel = c.name)")
+	case AllMode:
+	default:
+		return "", nil, fmt.Errorf("invalid mode %s. The valid options are %s", opts.Mode, strings.Join(Modes, ", "))
+	}
+
+	if len(opts.Packages) > 0 {
+		query = query.Where(sq.Eq{"e.package_name": opts.Packages})
+	}
+
+	query = query.OrderBy("o.name", "e.channel_name")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create sql : %s", err)
+	}
+	return sql, args, nil
+}
+
+// NewIndexReader opens the SQLite database of an index
+func NewIndexReader(path string) (*IndexReader, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("unable to connect in to the database : %s", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect in to the database : %s", err)
+	}
+
+	return &IndexReader{db: db}, nil
+}
+
+func (r *IndexReader) Close() error {
+	return r.db.Close()
+}
+
+// Packages returns the packages of the index with their default channel
+func (r *IndexReader) Packages() ([]Package, error) {
+	rows, err := sq.Select("name", "default_channel").
+		From("package").
+		OrderBy("name").
+		RunWith(r.db).Query()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query packages in the index db : %s", err)
+	}
+	defer rows.Close()
+
+	var packages []Package
+	for rows.Next() {
+		var defaultChannel sql.NullString
+		p := Package{}
+		if err := rows.Scan(&p.Name, &defaultChannel); err != nil {
+			return nil, fmt.Errorf("unable to scan package from index : %s", err)
+		}
+		p.DefaultChannel = defaultChannel.String
+		packages = append(packages, p)
+	}
+
+	return packages, rows.Err()
+}
+
+// Channels returns the channels of every package with their head bundle, ordered by package
+func (r *IndexReader) Channels() ([]Channel, error) {
+	rows, err := sq.Select("name", "package_name", "head_operatorbundle_name").
+		From("channel").
+		OrderBy("package_name", "name").
+		RunWith(r.db).Query()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query channels in the index db : %s", err)
+	}
+	defer rows.Close()
+
+	var channels []Channel
+	for rows.Next() {
+		var head sql.NullString
+		c := Channel{}
+		if err := rows.Scan(&c.Name, &c.PackageName, &head); err != nil {
+			return nil, fmt.Errorf("unable to scan channel from index : %s", err)
+		}
+		c.Head = head.String
+		channels = append(channels, c)
+	}
+
+	return channels, rows.Err()
+}
+
+// Bundles returns the bundles selected by the options with their channels and default channel
+func (r *IndexReader) Bundles(opts BundlesOptions) ([]Bundle, error) {
+	query, args, err := BuildBundlesQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query the index db : %s", err)
+	}
+	defer rows.Close()
+
+	var bundles []Bundle
+	var bundle *Bundle
+	for rows.Next() {
+		var bundleName string
+		var bundlePath, version, skipRange, replaces, skips, csv sql.NullString
+		var channelName, packageName, defaultChannel sql.NullString
+
+		if err := rows.Scan(&bundleName, &bundlePath, &version, &skipRange, &replaces, &skips, &csv,
+			&channelName, &packageName, &defaultChannel); err != nil {
+			return nil, fmt.Errorf("unable to scan data from index : %s", err)
+		}
+
+		// rows are ordered by bundle so one bundle spans consecutive rows, one per channel
+		if bundle == nil || bundle.Name != bundleName {
+			if bundle != nil {
+				bundles = append(bundles, *bundle)
+			}
+
+			log.Infof("Generating data from the bundle (%s)", bundleName)
+			bundle = NewBundle(bundleName, bundlePath.String)
+			bundle.Version = version.String
+			bundle.SkipRange = skipRange.String
+			bundle.Replaces = replaces.String
+			if len(skips.String) > 0 {
+				bundle.Skips = strings.Split(skips.String, ",")
+			}
+			bundle.Annotations, bundle.Labels = csvMetadataFromObject([]byte(csv.String))
+			bundle.OpenShiftVersions = bundle.metadata(OpenShiftVersionsAnnotation)
+		}
+
+		if packageName.Valid {
+			bundle.PackageName = packageName.String
+			bundle.DefaultChannel = defaultChannel.String
+		}
+
+		if channelName.Valid {
+			n := len(bundle.Channels)
+			if n == 0 || bundle.Channels[n-1] != channelName.String {
+				bundle.Channels = append(bundle.Channels, channelName.String)
+			}
+		}
+	}
+
+	if bundle != nil {
+		bundles = append(bundles, *bundle)
+	}
+
+	return bundles, rows.Err()
+}
+
+// IsValidMode reports whether the mode is one of the supported modes to select bundles
+func IsValidMode(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidOutputFormat reports whether the bundle list can be written in the format
+func IsValidOutputFormat(format string) bool {
+	for _, f := range OutputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
 }
 
 func NewBundle(bundleName, bundleImagePath string) *Bundle {
@@ -75,28 +548,146 @@ func NewBundle(bundleName, bundleImagePath string) *Bundle {
 	return &bundle
 }
 
-func (b *BundleList) OutputList() error {
+// OutputList writes the bundle list in the format to the file within the output path.
+// When the file name is - the list is written to the standard output.
+func (b *BundleList) OutputList(outputPath string, fileName string, format string) (string, error) {
 	b.fixPackageNameInconsistency()
 
-	data, err := json.Marshal(b)
-	if err != nil {
-		return err
+	var out bytes.Buffer
+	if err := b.encode(&out, format); err != nil {
+		return "", err
 	}
 
-	var prettyJSON bytes.Buffer
-	err = json.Indent(&prettyJSON, data, "", "\t")
-	if err != nil {
-		return err
+	if fileName == Stdout {
+		_, err := os.Stdout.Write(out.Bytes())
+		return fileName, err
 	}
 
-	path := "/tmp/bundlelist.json"
+	if len(outputPath) == 0 {
+		outputPath = DefaultOutputPath
+	}
 
-	_, err = ioutil.ReadFile(path)
+	if len(fileName) == 0 {
+		fileName = DefaultOutputName + "." + format
+	}
+
+	path := filepath.Join(outputPath, fileName)
+
+	_, err := ioutil.ReadFile(path)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	return path, ioutil.WriteFile(path, out.Bytes(), 0644)
+}
+
+func (b *BundleList) encode(out io.Writer, format string) error {
+	switch format {
+	case JSON:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+
+		var prettyJSON bytes.Buffer
+		if err := json.Indent(&prettyJSON, data, "", "\t"); err != nil {
+			return err
+		}
+
+		_, err = out.Write(prettyJSON.Bytes())
 		return err
+	case YAML:
+		data, err := sigsyaml.Marshal(b)
+		if err != nil {
+			return err
+		}
+
+		_, err = out.Write(data)
+		return err
+	case NDJSON:
+		// one bundle per line so the list can be streamed to other tools
+		encoder := json.NewEncoder(out)
+		for _, bundle := range b.Bundles {
+			if err := encoder.Encode(bundle); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CSV:
+		w := csv.NewWriter(out)
+		if err := w.Write(csvHeader); err != nil {
+			return err
+		}
+
+		for _, bundle := range b.Bundles {
+			if err := w.Write([]string{
+				bundle.Name,
+				bundle.PackageName,
+				bundle.DefaultChannel,
+				bundle.BundleImage,
+				strings.Join(bundle.Channels, csvListSeparator),
+				bundle.Version,
+				bundle.Replaces,
+				strings.Join(bundle.Skips, csvListSeparator),
+				bundle.SkipRange,
+				bundle.OpenShiftVersions,
+			}); err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+	}
+
+	return fmt.Errorf("invalid output format %s. The valid options are %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// ReadBundleList loads a BundleList previously written by OutputList
+func ReadBundleList(path string) (BundleList, error) {
+	list := BundleList{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return list, fmt.Errorf("unable to read the bundle list %s : %s", path, err)
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == "."+CSV {
+		return list, fmt.Errorf("unable to parse the bundle list %s : %s lists are not supported", path, CSV)
+	}
+
+	// handles the json, yaml and ndjson output formats
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return list, fmt.Errorf("unable to parse the bundle list %s : %s", path, err)
+		}
+
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return list, fmt.Errorf("unable to parse the bundle list %s : %s", path, err)
+		}
+
+		if _, ok := fields["Bundles"]; ok {
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return list, fmt.Errorf("unable to parse the bundle list %s : %s", path, err)
+			}
+			continue
+		}
+
+		bundle := Bundle{}
+		if err := json.Unmarshal(raw, &bundle); err != nil {
+			return list, fmt.Errorf("unable to parse the bundle list %s : %s", path, err)
+		}
+		list.Bundles = append(list.Bundles, bundle)
 	}
 
-	return ioutil.WriteFile(path, prettyJSON.Bytes(), 0644)
+	return list, nil
 }
 
 // fix inconsistency in the index db
@@ -121,3 +712,610 @@ func (b *BundleList) fixPackageNameInconsistency() {
 		}
 	}
 }
+
+// GetDataFromIndexDB reads the bundles from the extracted index.db and appends them to the BundleList
+func GetDataFromIndexDB(data BundleList, mode string) (BundleList, error) {
+	reader, err := NewIndexReader(indexDBOutputPath)
+	if err != nil {
+		return data, err
+	}
+	defer reader.Close()
+
+	bundles, err := reader.Bundles(BundlesOptions{Mode: mode})
+	if err != nil {
+		return data, err
+	}
+
+	data.Bundles = append(data.Bundles, bundles...)
+
+	return data, nil
+}
+
+// GetDataFromIndexConfigs reads the bundles from the file-based catalog directory and appends them to the BundleList
+func GetDataFromIndexConfigs(data BundleList, configsDir string, mode string) (BundleList, error) {
+	if !IsValidMode(mode) {
+		return data, fmt.Errorf("invalid mode %s. The valid options are %s", mode, strings.Join(Modes, ", "))
+	}
+
+	catalog, err := loadDeclarativeConfig(configsDir)
+	if err != nil {
+		return data, err
+	}
+
+	defaultChannels := map[string]string{}
+	for _, p := range catalog.packages {
+		defaultChannels[p.Name] = p.DefaultChannel
+	}
+
+	// bundle name -> channels, upgrade edges and whether it is the head of a (default) channel
+	channels := map[string][]string{}
+	entries := map[string]declarativeChannelEntry{}
+	heads := map[string]bool{}
+	defaultHeads := map[string]bool{}
+	for _, c := range catalog.channels {
+		for _, entry := range c.Entries {
+			channels[entry.Name] = append(channels[entry.Name], c.Name)
+			if _, ok := entries[entry.Name]; !ok {
+				entries[entry.Name] = entry
+			}
+		}
+
+		for _, head := range c.heads() {
+			heads[head] = true
+			if defaultChannels[c.Package] == c.Name {
+				defaultHeads[head] = true
+			}
+		}
+	}
+
+	sort.Slice(catalog.bundles, func(i, j int) bool {
+		return catalog.bundles[i].Name < catalog.bundles[j].Name
+	})
+
+	for _, b := range catalog.bundles {
+		if mode == HeadsMode && !heads[b.Name] {
+			continue
+		}
+		if mode == DefaultChannelHeadsMode && !defaultHeads[b.Name] {
+			continue
+		}
+
+		log.Infof("Generating data from the bundle (%s)", b.Name)
+		bundle := NewBundle(b.Name, b.Image)
+		bundle.PackageName = b.Package
+		bundle.DefaultChannel = defaultChannels[b.Package]
+		bundle.Channels = channels[b.Name]
+		bundle.Version = b.version()
+		bundle.Annotations, bundle.Labels = b.csvMetadata()
+		bundle.OpenShiftVersions = bundle.metadata(OpenShiftVersionsAnnotation)
+
+		entry := entries[b.Name]
+		bundle.Replaces = entry.Replaces
+		bundle.Skips = entry.Skips
+		bundle.SkipRange = entry.SkipRange
+
+		data.Bundles = append(data.Bundles, *bundle)
+	}
+
+	return data, nil
+}
+
+// loadDeclarativeConfig parses every JSON and YAML file of the file-based catalog directory
+func loadDeclarativeConfig(configsDir string) (declarativeConfig, error) {
+	catalog := declarativeConfig{}
+
+	err := filepath.Walk(configsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+		for {
+			blob := declarativeBlob{}
+			if err := decoder.Decode(&blob); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return fmt.Errorf("unable to parse %s : %s", path, err)
+			}
+
+			switch blob.Schema {
+			case packageSchema:
+				catalog.packages = append(catalog.packages, blob)
+			case channelSchema:
+				catalog.channels = append(catalog.channels, blob)
+			case bundleSchema:
+				catalog.bundles = append(catalog.bundles, blob)
+			}
+		}
+	})
+	if err != nil {
+		return catalog, fmt.Errorf("unable to read the file-based catalog %s : %s", configsDir, err)
+	}
+
+	return catalog, nil
+}
+
+// heads returns the entries of the channel which no other entry replaces or skips
+func (b declarativeBlob) heads() []string {
+	replaced := map[string]bool{}
+	for _, entry := range b.Entries {
+		replaced[entry.Replaces] = true
+		for _, skip := range entry.Skips {
+			replaced[skip] = true
+		}
+	}
+
+	var heads []string
+	for _, entry := range b.Entries {
+		if !replaced[entry.Name] {
+			heads = append(heads, entry.Name)
+		}
+	}
+
+	sort.Strings(heads)
+	return heads
+}
+
+// getCatalogFromIndexConfigs reads all the packages, channels and bundles of the file-based catalog
+func getCatalogFromIndexConfigs(configsDir string) (Catalog, error) {
+	config, err := loadDeclarativeConfig(configsDir)
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	catalog := Catalog{}
+	for _, p := range config.packages {
+		catalog.Packages = append(catalog.Packages, Package{Name: p.Name, DefaultChannel: p.DefaultChannel})
+	}
+
+	for _, c := range config.channels {
+		channel := Channel{Name: c.Name, PackageName: c.Package}
+		if heads := c.heads(); len(heads) > 0 {
+			channel.Head = heads[0]
+		}
+		catalog.Channels = append(catalog.Channels, channel)
+	}
+
+	list, err := GetDataFromIndexConfigs(BundleList{}, configsDir, AllMode)
+	if err != nil {
+		return Catalog{}, err
+	}
+	catalog.Bundles = list.Bundles
+
+	return catalog, nil
+}
+
+// getCatalogFromIndexDB reads all the packages, channels and bundles of the SQLite database
+func getCatalogFromIndexDB(path string) (Catalog, error) {
+	reader, err := NewIndexReader(path)
+	if err != nil {
+		return Catalog{}, err
+	}
+	defer reader.Close()
+
+	catalog := Catalog{}
+	catalog.Packages, err = reader.Packages()
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	channels, err := reader.Channels()
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	// the channels are loaded at once and kept for the packages of the index only
+	channelsByPackage := map[string][]Channel{}
+	for _, c := range channels {
+		channelsByPackage[c.PackageName] = append(channelsByPackage[c.PackageName], c)
+	}
+	for _, p := range catalog.Packages {
+		catalog.Channels = append(catalog.Channels, channelsByPackage[p.Name]...)
+	}
+
+	catalog.Bundles, err = reader.Bundles(BundlesOptions{Mode: AllMode})
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	return catalog, nil
+}
+
+// DiffCatalogs reports what changed from one catalog to the other
+func DiffCatalogs(from Catalog, to Catalog) CatalogDiff {
+	diff := CatalogDiff{}
+
+	fromPackages := map[string]Package{}
+	for _, p := range from.Packages {
+		fromPackages[p.Name] = p
+	}
+	toPackages := map[string]Package{}
+	for _, p := range to.Packages {
+		toPackages[p.Name] = p
+		previous, ok := fromPackages[p.Name]
+		if !ok {
+			diff.AddedPackages = append(diff.AddedPackages, p.Name)
+			continue
+		}
+		if previous.DefaultChannel != p.DefaultChannel {
+			diff.ChangedDefaultChannels = append(diff.ChangedDefaultChannels, DefaultChannelChange{
+				PackageName: p.Name,
+				From:        previous.DefaultChannel,
+				To:          p.DefaultChannel,
+			})
+		}
+	}
+	for _, p := range from.Packages {
+		if _, ok := toPackages[p.Name]; !ok {
+			diff.RemovedPackages = append(diff.RemovedPackages, p.Name)
+		}
+	}
+
+	fromHeads := map[string]string{}
+	for _, c := range from.Channels {
+		fromHeads[c.PackageName+"/"+c.Name] = c.Head
+	}
+	toHeads := map[string]string{}
+	for _, c := range to.Channels {
+		key := c.PackageName + "/" + c.Name
+		toHeads[key] = c.Head
+		if previous, ok := fromHeads[key]; !ok || previous != c.Head {
+			diff.ChangedHeads = append(diff.ChangedHeads, ChannelHeadChange{
+				PackageName: c.PackageName,
+				Channel:     c.Name,
+				From:        previous,
+				To:          c.Head,
+			})
+		}
+	}
+	for _, c := range from.Channels {
+		if _, ok := toHeads[c.PackageName+"/"+c.Name]; !ok {
+			diff.ChangedHeads = append(diff.ChangedHeads, ChannelHeadChange{
+				PackageName: c.PackageName,
+				Channel:     c.Name,
+				From:        c.Head,
+			})
+		}
+	}
+
+	fromBundles := map[string]Bundle{}
+	for _, b := range from.Bundles {
+		fromBundles[b.Name] = b
+	}
+	toBundles := map[string]Bundle{}
+	for _, b := range to.Bundles {
+		toBundles[b.Name] = b
+		previous, ok := fromBundles[b.Name]
+		if !ok {
+			diff.AddedBundles = append(diff.AddedBundles, b.Name)
+			continue
+		}
+		if bundleChanged(previous, b) {
+			diff.ChangedBundles = append(diff.ChangedBundles, b.Name)
+		}
+	}
+	for _, b := range from.Bundles {
+		if _, ok := toBundles[b.Name]; !ok {
+			diff.RemovedBundles = append(diff.RemovedBundles, b.Name)
+		}
+	}
+
+	// the heads which are new, changed or just became a head are the ones worth auditing
+	audit := map[string]bool{}
+	for _, name := range append(append([]string{}, diff.AddedBundles...), diff.ChangedBundles...) {
+		audit[name] = true
+	}
+	for _, change := range diff.ChangedHeads {
+		if len(change.To) > 0 {
+			audit[change.To] = true
+		}
+	}
+
+	for _, c := range to.Channels {
+		if audit[c.Head] {
+			if bundle, ok := toBundles[c.Head]; ok && !diff.Heads.contains(bundle.Name) {
+				diff.Heads.Bundles = append(diff.Heads.Bundles, bundle)
+			}
+		}
+	}
+
+	sort.Slice(diff.Heads.Bundles, func(i, j int) bool {
+		return diff.Heads.Bundles[i].Name < diff.Heads.Bundles[j].Name
+	})
+
+	return diff
+}
+
+// Print writes the summary of the changes between the catalogs
+func (d CatalogDiff) Print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "CHANGE\tPACKAGE\tCHANNEL\tFROM\tTO")
+	for _, name := range d.AddedPackages {
+		fmt.Fprintf(w, "package added\t%s\t\t\t\n", name)
+	}
+	for _, name := range d.RemovedPackages {
+		fmt.Fprintf(w, "package removed\t%s\t\t\t\n", name)
+	}
+	for _, change := range d.ChangedDefaultChannels {
+		fmt.Fprintf(w, "default channel changed\t%s\t\t%s\t%s\n", change.PackageName, change.From, change.To)
+	}
+	for _, change := range d.ChangedHeads {
+		fmt.Fprintf(w, "channel head changed\t%s\t%s\t%s\t%s\n", change.PackageName, change.Channel,
+			change.From, change.To)
+	}
+	for _, name := range d.AddedBundles {
+		fmt.Fprintf(w, "bundle added\t\t\t\t%s\n", name)
+	}
+	for _, name := range d.RemovedBundles {
+		fmt.Fprintf(w, "bundle removed\t\t\t%s\t\n", name)
+	}
+	for _, name := range d.ChangedBundles {
+		fmt.Fprintf(w, "bundle changed\t\t\t%s\t%s\n", name, name)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\n%d heads to audit\n", len(d.Heads.Bundles))
+	return err
+}
+
+func (b BundleList) contains(name string) bool {
+	for _, bundle := range b.Bundles {
+		if bundle.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleChanged reports whether the bundle was republished with a different content or upgrade graph
+func bundleChanged(from Bundle, to Bundle) bool {
+	return from.BundleImage != to.BundleImage ||
+		from.Version != to.Version ||
+		from.Replaces != to.Replaces ||
+		from.SkipRange != to.SkipRange ||
+		strings.Join(from.Skips, ",") != strings.Join(to.Skips, ",") ||
+		strings.Join(from.Channels, ",") != strings.Join(to.Channels, ",")
+}
+
+// version returns the version of the bundle from its olm.package property
+func (b declarativeBlob) version() string {
+	for _, property := range b.Properties {
+		if property.Type != packageSchema {
+			continue
+		}
+
+		value := struct {
+			Version string `json:"version"`
+		}{}
+		if err := json.Unmarshal(property.Value, &value); err == nil {
+			return value.Version
+		}
+	}
+	return ""
+}
+
+// csvMetadata returns the annotations and labels of the bundle's ClusterServiceVersion
+// from its olm.csv.metadata or olm.bundle.object properties
+func (b declarativeBlob) csvMetadata() (map[string]string, map[string]string) {
+	for _, property := range b.Properties {
+		switch property.Type {
+		case csvMetadataProperty:
+			metadata := csvMetadata{}
+			if err := json.Unmarshal(property.Value, &metadata); err == nil {
+				return metadata.Annotations, metadata.Labels
+			}
+		case bundleObjectProperty:
+			value := struct {
+				Data []byte `json:"data"`
+			}{}
+			if err := json.Unmarshal(property.Value, &value); err != nil {
+				continue
+			}
+
+			annotations, labels := csvMetadataFromObject(value.Data)
+			if annotations != nil || labels != nil {
+				return annotations, labels
+			}
+		}
+	}
+	return nil, nil
+}
+
+// csvMetadataFromObject returns the annotations and labels of the object when it is a ClusterServiceVersion
+func csvMetadataFromObject(data []byte) (map[string]string, map[string]string) {
+	object := struct {
+		Kind     string      `json:"kind"`
+		Metadata csvMetadata `json:"metadata"`
+	}{}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if err := yaml.Unmarshal(data, &object); err != nil || object.Kind != csvKind {
+		return nil, nil
+	}
+
+	return object.Metadata.Annotations, object.Metadata.Labels
+}
+
+// AddFilterFlags binds the flags used to select a slice of the bundles of the index
+func AddFilterFlags(cmd *cobra.Command, filter *BundleFilter) {
+	cmd.Flags().StringSliceVar(&filter.IncludePackages, "include-packages", nil,
+		"only keep the bundles of these packages. Patterns are shell globs (e.g. etcd*), "+
+			"or regular expressions when enclosed in slashes (e.g. /^etcd.*$/).")
+	cmd.Flags().StringSliceVar(&filter.ExcludePackages, "exclude-packages", nil,
+		"drop the bundles of these packages. Same pattern syntax as --include-packages.")
+	cmd.Flags().StringVar(&filter.Label, "label", "",
+		fmt.Sprintf("only keep the bundles whose CSV has this annotation or label (e.g. %s).",
+			InfrastructureAnnotation))
+	cmd.Flags().StringVar(&filter.LabelValue, "label-value", "",
+		"value the annotation or label informed with --label must have. For annotations holding a JSON "+
+			"list, such as the infrastructure features, the value must be one of the list elements.")
+	cmd.Flags().Int32Var(&filter.Limit, "limit", 0,
+		"maximum number of bundles to keep. No limit when 0.")
+}
+
+// Validate checks the package patterns of the filter
+func (f BundleFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.IncludePackages...), f.ExcludePackages...) {
+		if _, err := matchPackage(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %s : %s", pattern, err)
+		}
+	}
+
+	if len(f.LabelValue) > 0 && len(f.Label) == 0 {
+		return fmt.Errorf("--label-value requires --label")
+	}
+
+	if f.Limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+
+	return nil
+}
+
+// Filter returns the bundles of the list selected by the filter
+func (b BundleList) Filter(filter BundleFilter) BundleList {
+	filtered := BundleList{}
+
+	for _, bundle := range b.Bundles {
+		if filter.Limit > 0 && len(filtered.Bundles) >= int(filter.Limit) {
+			break
+		}
+
+		if len(filter.IncludePackages) > 0 && !matchAnyPackage(filter.IncludePackages, bundle.PackageName) {
+			continue
+		}
+
+		if matchAnyPackage(filter.ExcludePackages, bundle.PackageName) {
+			continue
+		}
+
+		if len(filter.Label) > 0 && !bundle.HasLabel(filter.Label, filter.LabelValue) {
+			continue
+		}
+
+		filtered.Bundles = append(filtered.Bundles, bundle)
+	}
+
+	log.Infof("%d of %d bundles selected", len(filtered.Bundles), len(b.Bundles))
+
+	return filtered
+}
+
+// HasLabel reports whether the CSV of the bundle has the annotation or label with the value.
+// An empty value only checks the key is present.
+func (b Bundle) HasLabel(key string, value string) bool {
+	current, ok := b.lookupMetadata(key)
+	if !ok {
+		return false
+	}
+
+	if len(value) == 0 {
+		return true
+	}
+
+	// annotations such as the infrastructure features hold a JSON list
+	var values []string
+	if err := json.Unmarshal([]byte(current), &values); err == nil {
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return strings.EqualFold(current, value)
+}
+
+// lookupMetadata returns the annotation, or else the label, of the CSV of the bundle
+func (b Bundle) lookupMetadata(key string) (string, bool) {
+	value, ok := b.Annotations[key]
+	if !ok {
+		value, ok = b.Labels[key]
+	}
+
+	return value, ok
+}
+
+// metadata returns the annotation, or else the label, of the CSV of the bundle, or empty when it has neither
+func (b Bundle) metadata(key string) string {
+	value, _ := b.lookupMetadata(key)
+	return value
+}
+
+// BundleImageOpenShiftVersions returns the com.redhat.openshift.versions label of the bundle image, or empty
+// when the image does not have it
+func BundleImageOpenShiftVersions(image string) (string, error) {
+	img, err := LoadImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := img.ConfigFile()
+	if err != nil {
+		return "", fmt.Errorf("unable to read the config of the bundle image %s : %s", image, err)
+	}
+
+	return config.Config.Labels[OpenShiftVersionsAnnotation], nil
+}
+
+// SetOpenShiftVersionsFromImages reads the supported OpenShift versions from the bundle images of the bundles
+// which did not get them from their ClusterServiceVersion. The bundles whose image cannot be read are left
+// as supporting every version.
+func (b *BundleList) SetOpenShiftVersionsFromImages() {
+	for i, bundle := range b.Bundles {
+		if len(bundle.OpenShiftVersions) > 0 || len(bundle.BundleImage) == 0 {
+			continue
+		}
+
+		versions, err := BundleImageOpenShiftVersions(bundle.BundleImage)
+		if err != nil {
+			log.Warnf("Unable to read the OpenShift versions of bundle %s: %v\n", bundle.Name, err)
+			continue
+		}
+
+		b.Bundles[i].OpenShiftVersions = versions
+	}
+}
+
+func matchAnyPackage(patterns []string, packageName string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := matchPackage(pattern, packageName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPackage matches the package name against a shell glob, or a regular expression when the
+// pattern is enclosed in slashes
+func matchPackage(pattern string, packageName string) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(packageName), nil
+	}
+
+	return path.Match(pattern, packageName)
+}
@@ -0,0 +1,472 @@
+package index
+
+import (
+	. "audit-tool-orchestrator/pkg"
+	"bytes"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// platformLabel is set on the images of the OCI layout fixtures with the platform they are built for
+const platformLabel = "platform"
+
+var amd64 = v1.Platform{OS: "linux", Architecture: "amd64"}
+var arm64 = v1.Platform{OS: "linux", Architecture: "arm64"}
+
+// newPlatformImage returns an empty image labelled with the platform
+func newPlatformImage(t *testing.T, platform string) v1.Image {
+	t.Helper()
+
+	img, err := mutate.Config(empty.Image, v1.Config{Labels: map[string]string{platformLabel: platform}})
+	if err != nil {
+		t.Fatalf("unable to create the image of %s: %v", platform, err)
+	}
+
+	return img
+}
+
+// newMultiArchIndex returns an image index with an image per platform, in the order of the platforms
+func newMultiArchIndex(t *testing.T, platforms ...v1.Platform) v1.ImageIndex {
+	t.Helper()
+
+	var adds []mutate.IndexAddendum
+	for _, platform := range platforms {
+		platform := platform
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        newPlatformImage(t, platform.OS+"/"+platform.Architecture),
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	return mutate.AppendManifests(empty.Index, adds...)
+}
+
+func TestLoadImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  func(t *testing.T, path layout.Path) error
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "single image",
+			layout: func(t *testing.T, path layout.Path) error {
+				return path.AppendImage(newPlatformImage(t, "single"))
+			},
+			want: "single",
+		},
+		{
+			name: "multi-arch index",
+			layout: func(t *testing.T, path layout.Path) error {
+				return path.AppendIndex(newMultiArchIndex(t, arm64, amd64))
+			},
+			want: "linux/amd64",
+		},
+		{
+			name: "multi-arch index without the default platform",
+			layout: func(t *testing.T, path layout.Path) error {
+				return path.AppendIndex(newMultiArchIndex(t, arm64))
+			},
+			want: "linux/arm64",
+		},
+		{
+			name: "empty layout",
+			layout: func(t *testing.T, path layout.Path) error {
+				return nil
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "index")
+			path, err := layout.Write(dir, empty.Index)
+			if err != nil {
+				t.Fatalf("unable to write the OCI layout: %v", err)
+			}
+			if err := tt.layout(t, path); err != nil {
+				t.Fatalf("unable to add the images to the OCI layout: %v", err)
+			}
+
+			img, err := LoadImage(OCILayoutTransport + dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			config, err := img.ConfigFile()
+			if err != nil {
+				t.Fatalf("unable to read the image config: %v", err)
+			}
+			if got := config.Config.Labels[platformLabel]; got != tt.want {
+				t.Errorf("LoadImage() = image of %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadImage(OCILayoutTransport + filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("LoadImage() error = nil for a missing OCI layout")
+	}
+}
+
+// testConfigsDir is a file-based catalog of the etcd and prometheus packages, the etcd bundles spread over two
+// channels
+const testConfigsDir = "testdata/configs"
+
+func bundleNames(list BundleList) []string {
+	var names []string
+	for _, bundle := range list.Bundles {
+		names = append(names, bundle.Name)
+	}
+	return names
+}
+
+func TestGetDataFromIndexConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "all bundles",
+			mode: AllMode,
+			want: []string{"etcdoperator.v0.9.2-clusterwide", "etcdoperator.v0.9.4",
+				"etcdoperator.v0.9.4-clusterwide", "prometheusoperator.0.47.0"},
+		},
+		{
+			name: "channel heads",
+			mode: HeadsMode,
+			want: []string{"etcdoperator.v0.9.4", "etcdoperator.v0.9.4-clusterwide", "prometheusoperator.0.47.0"},
+		},
+		{
+			name: "default channel heads",
+			mode: DefaultChannelHeadsMode,
+			want: []string{"etcdoperator.v0.9.4-clusterwide", "prometheusoperator.0.47.0"},
+		},
+		{
+			name:    "invalid mode",
+			mode:    "latest",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetDataFromIndexConfigs(BundleList{}, testConfigsDir, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDataFromIndexConfigs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if names := bundleNames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("GetDataFromIndexConfigs() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDataFromIndexConfigsBundles(t *testing.T) {
+	list, err := GetDataFromIndexConfigs(BundleList{}, testConfigsDir, AllMode)
+	if err != nil {
+		t.Fatalf("GetDataFromIndexConfigs() error = %v", err)
+	}
+
+	got := map[string]Bundle{}
+	for _, bundle := range list.Bundles {
+		got[bundle.Name] = bundle
+	}
+
+	want := []Bundle{
+		{
+			// the upgrade edges come from the channel entry
+			Name:           "etcdoperator.v0.9.4-clusterwide",
+			PackageName:    "etcd",
+			DefaultChannel: "clusterwide-alpha",
+			BundleImage:    "quay.io/operatorhubio/etcd:v0.9.4-clusterwide",
+			Channels:       []string{"clusterwide-alpha"},
+			Version:        "0.9.4-clusterwide",
+			Replaces:       "etcdoperator.v0.9.2-clusterwide",
+			SkipRange:      ">=0.9.0 <0.9.4",
+			// the metadata come from the olm.csv.metadata property
+			OpenShiftVersions: "v4.9",
+			Annotations: map[string]string{
+				OpenShiftVersionsAnnotation: "v4.9",
+				InfrastructureAnnotation:    `["disconnected", "proxy-aware"]`,
+			},
+		},
+		{
+			Name:           "etcdoperator.v0.9.4",
+			PackageName:    "etcd",
+			DefaultChannel: "clusterwide-alpha",
+			BundleImage:    "quay.io/operatorhubio/etcd:v0.9.4",
+			Channels:       []string{"singlenamespace-alpha"},
+			Version:        "0.9.4",
+			// the metadata come from the ClusterServiceVersion of the olm.bundle.object property
+			OpenShiftVersions: "v4.8-v4.10",
+			Annotations:       map[string]string{OpenShiftVersionsAnnotation: "v4.8-v4.10"},
+			Labels:            map[string]string{"operatorframework.io/arch.amd64": "supported"},
+		},
+		{
+			// the JSON blobs are read as the YAML ones
+			Name:           "prometheusoperator.0.47.0",
+			PackageName:    "prometheus",
+			DefaultChannel: "beta",
+			BundleImage:    "quay.io/operatorhubio/prometheus:v0.47.0",
+			Channels:       []string{"beta"},
+			Version:        "0.47.0",
+		},
+	}
+
+	for _, bundle := range want {
+		if !reflect.DeepEqual(got[bundle.Name], bundle) {
+			t.Errorf("GetDataFromIndexConfigs() bundle = %+v, want %+v", got[bundle.Name], bundle)
+		}
+	}
+}
+
+func TestBundleListFilter(t *testing.T) {
+	list := BundleList{Bundles: []Bundle{
+		{Name: "etcdoperator.v0.9.4", PackageName: "etcd",
+			Annotations: map[string]string{InfrastructureAnnotation: `["disconnected", "proxy-aware"]`}},
+		{Name: "prometheusoperator.0.47.0", PackageName: "prometheus",
+			Labels: map[string]string{"operatorframework.io/arch.amd64": "supported"}},
+		{Name: "strimzi-cluster-operator.v0.28.0", PackageName: "strimzi-kafka-operator",
+			Annotations: map[string]string{InfrastructureAnnotation: `["Disconnected"]`}},
+		{Name: "jaeger-operator.v1.34.1", PackageName: "jaeger"},
+	}}
+
+	tests := []struct {
+		name   string
+		filter BundleFilter
+		want   []string
+	}{
+		{
+			name: "no filter",
+			want: []string{"etcdoperator.v0.9.4", "prometheusoperator.0.47.0", "strimzi-cluster-operator.v0.28.0",
+				"jaeger-operator.v1.34.1"},
+		},
+		{
+			name:   "included packages glob",
+			filter: BundleFilter{IncludePackages: []string{"*o*"}},
+			want:   []string{"prometheusoperator.0.47.0", "strimzi-cluster-operator.v0.28.0"},
+		},
+		{
+			name:   "included packages regular expression",
+			filter: BundleFilter{IncludePackages: []string{"/^(etcd|jaeger)$/"}},
+			want:   []string{"etcdoperator.v0.9.4", "jaeger-operator.v1.34.1"},
+		},
+		{
+			name:   "excluded packages",
+			filter: BundleFilter{ExcludePackages: []string{"strimzi-*", "/^jae/"}},
+			want:   []string{"etcdoperator.v0.9.4", "prometheusoperator.0.47.0"},
+		},
+		{
+			name: "excluded packages take precedence",
+			filter: BundleFilter{IncludePackages: []string{"etcd", "prometheus"},
+				ExcludePackages: []string{"prometheus"}},
+			want: []string{"etcdoperator.v0.9.4"},
+		},
+		{
+			name:   "annotation present",
+			filter: BundleFilter{Label: InfrastructureAnnotation},
+			want:   []string{"etcdoperator.v0.9.4", "strimzi-cluster-operator.v0.28.0"},
+		},
+		{
+			name:   "annotation list element ignoring the case",
+			filter: BundleFilter{Label: InfrastructureAnnotation, LabelValue: "disconnected"},
+			want:   []string{"etcdoperator.v0.9.4", "strimzi-cluster-operator.v0.28.0"},
+		},
+		{
+			name:   "annotation list element missing",
+			filter: BundleFilter{Label: InfrastructureAnnotation, LabelValue: "proxy-aware"},
+			want:   []string{"etcdoperator.v0.9.4"},
+		},
+		{
+			name:   "label value",
+			filter: BundleFilter{Label: "operatorframework.io/arch.amd64", LabelValue: "supported"},
+			want:   []string{"prometheusoperator.0.47.0"},
+		},
+		{
+			name:   "limit applied after the other filters",
+			filter: BundleFilter{ExcludePackages: []string{"etcd"}, Limit: 2},
+			want:   []string{"prometheusoperator.0.47.0", "strimzi-cluster-operator.v0.28.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bundleNames(list.Filter(tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundleFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  BundleFilter
+		wantErr bool
+	}{
+		{
+			name:   "valid patterns",
+			filter: BundleFilter{IncludePackages: []string{"etcd*", "/^prom/"}, ExcludePackages: []string{"jaeger"}},
+		},
+		{
+			name:    "invalid glob",
+			filter:  BundleFilter{IncludePackages: []string{"etcd["}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regular expression",
+			filter:  BundleFilter{ExcludePackages: []string{"/(etcd/"}},
+			wantErr: true,
+		},
+		{
+			name:    "label value without label",
+			filter:  BundleFilter{LabelValue: "disconnected"},
+			wantErr: true,
+		},
+		{
+			name:    "negative limit",
+			filter:  BundleFilter{Limit: -1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffCatalogs(t *testing.T) {
+	etcd := Bundle{Name: "etcdoperator.v0.9.4", PackageName: "etcd", BundleImage: "quay.io/operatorhubio/etcd:v0.9.4",
+		Channels: []string{"alpha"}, Version: "0.9.4"}
+	etcdNext := Bundle{Name: "etcdoperator.v0.9.5", PackageName: "etcd", BundleImage: "quay.io/operatorhubio/etcd:v0.9.5",
+		Channels: []string{"alpha"}, Version: "0.9.5", Replaces: etcd.Name}
+	prometheus := Bundle{Name: "prometheusoperator.0.47.0", PackageName: "prometheus",
+		BundleImage: "quay.io/operatorhubio/prometheus:v0.47.0", Channels: []string{"beta"}, Version: "0.47.0"}
+	republished := prometheus
+	republished.BundleImage = "quay.io/operatorhubio/prometheus@sha256:0a1b"
+	jaeger := Bundle{Name: "jaeger-operator.v1.34.1", PackageName: "jaeger",
+		BundleImage: "quay.io/operatorhubio/jaeger:v1.34.1", Channels: []string{"stable"}, Version: "1.34.1"}
+
+	from := Catalog{
+		Packages: []Package{{Name: "etcd", DefaultChannel: "alpha"}, {Name: "prometheus", DefaultChannel: "beta"},
+			{Name: "jaeger", DefaultChannel: "stable"}},
+		Channels: []Channel{{Name: "alpha", PackageName: "etcd", Head: etcd.Name},
+			{Name: "beta", PackageName: "prometheus", Head: prometheus.Name},
+			{Name: "stable", PackageName: "jaeger", Head: jaeger.Name}},
+		Bundles: []Bundle{etcd, prometheus, jaeger},
+	}
+
+	tests := []struct {
+		name string
+		to   Catalog
+		want CatalogDiff
+	}{
+		{
+			name: "same catalog",
+			to:   from,
+			want: CatalogDiff{},
+		},
+		{
+			name: "new channel head",
+			to: Catalog{
+				Packages: from.Packages,
+				Channels: []Channel{{Name: "alpha", PackageName: "etcd", Head: etcdNext.Name}, from.Channels[1],
+					from.Channels[2]},
+				Bundles: []Bundle{etcd, etcdNext, prometheus, jaeger},
+			},
+			want: CatalogDiff{
+				ChangedHeads: []ChannelHeadChange{{PackageName: "etcd", Channel: "alpha", From: etcd.Name,
+					To: etcdNext.Name}},
+				AddedBundles: []string{etcdNext.Name},
+				Heads:        BundleList{Bundles: []Bundle{etcdNext}},
+			},
+		},
+		{
+			name: "republished head and changed default channel",
+			to: Catalog{
+				Packages: []Package{from.Packages[0], {Name: "prometheus", DefaultChannel: "stable"},
+					from.Packages[2]},
+				Channels: from.Channels,
+				Bundles:  []Bundle{etcd, republished, jaeger},
+			},
+			want: CatalogDiff{
+				ChangedDefaultChannels: []DefaultChannelChange{{PackageName: "prometheus", From: "beta",
+					To: "stable"}},
+				ChangedBundles: []string{prometheus.Name},
+				Heads:          BundleList{Bundles: []Bundle{republished}},
+			},
+		},
+		{
+			name: "removed package",
+			to: Catalog{
+				Packages: from.Packages[:2],
+				Channels: from.Channels[:2],
+				Bundles:  []Bundle{etcd, prometheus},
+			},
+			want: CatalogDiff{
+				RemovedPackages: []string{"jaeger"},
+				ChangedHeads:    []ChannelHeadChange{{PackageName: "jaeger", Channel: "stable", From: jaeger.Name}},
+				RemovedBundles:  []string{jaeger.Name},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffCatalogs(from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffCatalogs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogDiffPrint(t *testing.T) {
+	diff := CatalogDiff{
+		AddedPackages:          []string{"jaeger"},
+		ChangedDefaultChannels: []DefaultChannelChange{{PackageName: "prometheus", From: "beta", To: "stable"}},
+		ChangedHeads: []ChannelHeadChange{{PackageName: "etcd", Channel: "alpha", From: "etcdoperator.v0.9.4",
+			To: "etcdoperator.v0.9.5"}},
+		AddedBundles:   []string{"etcdoperator.v0.9.5"},
+		RemovedBundles: []string{"etcdoperator.v0.9.2"},
+		Heads:          BundleList{Bundles: []Bundle{{Name: "etcdoperator.v0.9.5"}}},
+	}
+
+	var out bytes.Buffer
+	if err := diff.Print(&out); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	// the padding of the empty columns is left out
+	want := `CHANGE                   PACKAGE     CHANNEL  FROM                 TO
+package added            jaeger
+default channel changed  prometheus           beta                 stable
+channel head changed     etcd        alpha    etcdoperator.v0.9.4  etcdoperator.v0.9.5
+bundle added                                                       etcdoperator.v0.9.5
+bundle removed                                etcdoperator.v0.9.2
+
+1 heads to audit
+`
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Errorf("Print() = \n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,9 +1,56 @@
 package index
 
+import (
+	"database/sql"
+	"encoding/json"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
 type BundleFlags struct {
-	IndexImage      string `json:"image"`
-	OutputPath      string `json:"outputPath"`
-	ContainerEngine string `json:"containerEngine"`
+	IndexImage      string       `json:"image"`
+	OutputPath      string       `json:"outputPath"`
+	OutputFile      string       `json:"outputFile"`
+	OutputFormat    string       `json:"outputFormat"`
+	ContainerEngine string       `json:"containerEngine"`
+	Mode            string       `json:"mode"`
+	Filter          BundleFilter `json:"filter"`
+}
+
+// BundleFilter selects a slice of the bundles of the index
+type BundleFilter struct {
+	IncludePackages []string `json:"includePackages"`
+	ExcludePackages []string `json:"excludePackages"`
+	Label           string   `json:"label"`
+	LabelValue      string   `json:"labelValue"`
+	Limit           int32    `json:"limit"`
+}
+
+// indexImage is an image manifest of an image index, with the index it is read from
+type indexImage struct {
+	index      v1.ImageIndex
+	descriptor v1.Descriptor
+}
+
+// IndexReader reads the packages, channels and bundles of an index SQLite database
+type IndexReader struct {
+	db *sql.DB
+}
+
+// BundlesOptions selects the bundles returned by IndexReader.Bundles
+type BundlesOptions struct {
+	Mode     string
+	Packages []string
+}
+
+type Package struct {
+	Name           string `json:"name"`
+	DefaultChannel string `json:"defaultChannel"`
+}
+
+type Channel struct {
+	Name        string `json:"name"`
+	PackageName string `json:"packageName"`
+	Head        string `json:"head"`
 }
 
 type BundleList struct {
@@ -16,4 +63,94 @@ type Bundle struct {
 	DefaultChannel string   `json:"defaultChannel"`
 	BundleImage    string   `json:"bundleImage"`
 	Channels       []string `json:"channels"`
+	Version        string   `json:"version"`
+	Replaces       string   `json:"replaces,omitempty"`
+	Skips          []string `json:"skips,omitempty"`
+	SkipRange      string   `json:"skipRange,omitempty"`
+	// OpenShiftVersions is the range of OpenShift versions the bundle supports, from its
+	// com.redhat.openshift.versions annotation. Every version when empty.
+	OpenShiftVersions string `json:"openshiftVersions,omitempty"`
+
+	// metadata of the bundle's ClusterServiceVersion used to filter the bundles, kept in the list so the
+	// bundles read back with --bundle-list can still be filtered
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// declarativeConfig holds the blobs of a file-based catalog
+type declarativeConfig struct {
+	packages []declarativeBlob
+	channels []declarativeBlob
+	bundles  []declarativeBlob
+}
+
+// declarativeBlob is one olm.package, olm.channel or olm.bundle object of a file-based catalog
+type declarativeBlob struct {
+	Schema         string                    `json:"schema"`
+	Name           string                    `json:"name"`
+	Package        string                    `json:"package"`
+	DefaultChannel string                    `json:"defaultChannel"`
+	Image          string                    `json:"image"`
+	Entries        []declarativeChannelEntry `json:"entries"`
+	Properties     []declarativeProperty     `json:"properties"`
+}
+
+type declarativeChannelEntry struct {
+	Name      string   `json:"name"`
+	Replaces  string   `json:"replaces"`
+	Skips     []string `json:"skips"`
+	SkipRange string   `json:"skipRange"`
+}
+
+type declarativeProperty struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type csvMetadata struct {
+	Annotations map[string]string `json:"annotations"`
+	Labels      map[string]string `json:"labels"`
+}
+
+// Catalog holds all the packages, channels and bundles of an index
+type Catalog struct {
+	Packages []Package
+	Channels []Channel
+	Bundles  []Bundle
+}
+
+type DiffFlags struct {
+	From            string `json:"from"`
+	To              string `json:"to"`
+	ContainerEngine string `json:"containerEngine"`
+	OutputPath      string `json:"outputPath"`
+	OutputFile      string `json:"outputFile"`
+	OutputFormat    string `json:"outputFormat"`
+}
+
+// CatalogDiff is what changed from one index to another
+type CatalogDiff struct {
+	AddedPackages          []string               `json:"addedPackages"`
+	RemovedPackages        []string               `json:"removedPackages"`
+	ChangedDefaultChannels []DefaultChannelChange `json:"changedDefaultChannels"`
+	ChangedHeads           []ChannelHeadChange    `json:"changedHeads"`
+	AddedBundles           []string               `json:"addedBundles"`
+	RemovedBundles         []string               `json:"removedBundles"`
+	ChangedBundles         []string               `json:"changedBundles"`
+
+	// Heads are the channel heads of the new index which are new or changed
+	Heads BundleList `json:"heads"`
+}
+
+type DefaultChannelChange struct {
+	PackageName string `json:"packageName"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+}
+
+type ChannelHeadChange struct {
+	PackageName string `json:"packageName"`
+	Channel     string `json:"channel"`
+	From        string `json:"from"`
+	To          string `json:"to"`
 }
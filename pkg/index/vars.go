@@ -1,3 +1,60 @@
 package index
 
+import (
+	. "audit-tool-orchestrator/pkg"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
 const catalogIndex = "audit-catalog-index"
+
+// ConfigsLabel is set on file-based catalog images with the directory of the declarative config
+const ConfigsLabel = "operators.operatorframework.io.index.configs.v1"
+
+const configsOutputDir = "/tmp/ato/output/configs"
+const indexDBOutputPath = "/tmp/ato/output/index.db"
+
+// indexDBPath is the location of the SQLite database in the index image
+const indexDBPath = "database/index.db"
+
+// transports of the index images read from the local filesystem
+const OCILayoutTransport = "oci:"
+const DockerArchiveTransport = "docker-archive:"
+
+// defaultPlatform is the platform of the image read from an OCI layout holding a multi-arch index image
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// schemas of the file-based catalog blobs
+const packageSchema = "olm.package"
+const channelSchema = "olm.channel"
+const bundleSchema = "olm.bundle"
+
+// properties of the file-based catalog bundles holding the ClusterServiceVersion metadata
+const csvMetadataProperty = "olm.csv.metadata"
+const bundleObjectProperty = "olm.bundle.object"
+
+const csvKind = "ClusterServiceVersion"
+
+// OpenShiftVersionsAnnotation tells the OpenShift versions the bundle supports, e.g. v4.8-v4.10. It is set on the
+// bundle image and may be copied to the ClusterServiceVersion.
+const OpenShiftVersionsAnnotation = "com.redhat.openshift.versions"
+
+// modes to select the bundles of the index
+const HeadsMode = "heads"
+const AllMode = "all"
+const DefaultChannelHeadsMode = "default-channel-heads"
+
+var Modes = []string{HeadsMode, AllMode, DefaultChannelHeadsMode}
+
+// DefaultOutputPath and DefaultOutputName are used when the bundle list location is not informed
+const DefaultOutputPath = "/tmp"
+const DefaultOutputName = "bundlelist"
+
+// Stdout as the output file name writes the bundle list to the standard output
+const Stdout = "-"
+
+var OutputFormats = []string{JSON, YAML, CSV, NDJSON}
+
+const csvListSeparator = ";"
+
+var csvHeader = []string{"name", "packageName", "defaultChannel", "bundleImage", "channels", "version", "replaces",
+	"skips", "skipRange", "openshiftVersions"}
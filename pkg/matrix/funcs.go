@@ -0,0 +1,166 @@
+package matrix
+
+import (
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/release"
+	"audit-tool-orchestrator/pkg/state"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	yamlv3 "gopkg.in/yaml.v3"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// ReadPools returns the pools of the matrix file, one for each OpenShift version on each platform. The
+// settings of each platform are set on top of the pool flags, which also apply to the matrix without platforms.
+func ReadPools(path string, defaults orchestrate.PoolFlags) ([]orchestrate.PoolFlags, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the pool matrix %s : %s", path, err)
+	}
+
+	matrix := Matrix{}
+	if err := yaml.UnmarshalStrict(data, &matrix); err != nil {
+		return nil, fmt.Errorf("unable to parse the pool matrix %s : %s", path, err)
+	}
+
+	matrix.OpenShift, err = openShiftVersions(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the pool matrix %s : %s", path, err)
+	}
+
+	prefix := matrix.Name
+	if len(prefix) == 0 {
+		prefix = defaults.Name
+	}
+
+	platforms := matrix.Platforms
+	if len(platforms) == 0 {
+		platforms = []json.RawMessage{json.RawMessage("{}")}
+	}
+
+	var pools []orchestrate.PoolFlags
+	names := map[string]bool{}
+	for _, settings := range platforms {
+		base := defaults
+		decoder := json.NewDecoder(bytes.NewReader(settings))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&base); err != nil {
+			return nil, fmt.Errorf("unable to parse the platforms of the pool matrix %s : %s", path, err)
+		}
+
+		if len(base.Platform) == 0 {
+			base.Platform = orchestrate.DefaultPlatform
+		}
+		if !orchestrate.IsValidPlatform(base.Platform) {
+			return nil, fmt.Errorf("invalid platform %s in the pool matrix %s. The valid options are %s",
+				base.Platform, path, strings.Join(orchestrate.Platforms, ", "))
+		}
+
+		versions := matrix.OpenShift
+		if len(versions) == 0 && len(base.OpenShift) > 0 && base.OpenShift != release.LatestVersion {
+			versions = []string{base.OpenShift}
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("the pool matrix %s has no OpenShift version for the platform %s",
+				path, base.Platform)
+		}
+
+		for _, version := range versions {
+			if _, err := release.MinorVersion(version); err != nil {
+				return nil, fmt.Errorf("invalid pool matrix %s : %s", path, err)
+			}
+
+			pool := base
+			pool.OpenShift = version
+			pool.Name = poolName(prefix, version, pool.Platform)
+			if names[pool.Name] {
+				return nil, fmt.Errorf("the pool matrix %s has more than one pool named %s", path, pool.Name)
+			}
+			names[pool.Name] = true
+
+			pools = append(pools, pool)
+		}
+	}
+
+	return pools, nil
+}
+
+// openShiftVersions returns the OpenShift versions of the matrix as written in the file, since an unquoted
+// version such as 4.10 is otherwise read as the number 4.1
+func openShiftVersions(data []byte) ([]string, error) {
+	matrix := struct {
+		OpenShift []yamlv3.Node `yaml:"openshift"`
+	}{}
+	if err := yamlv3.Unmarshal(data, &matrix); err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, node := range matrix.OpenShift {
+		versions = append(versions, node.Value)
+	}
+
+	return versions, nil
+}
+
+// poolName returns the name of the pool of the matrix for the version and platform, which is valid for
+// k8s resources
+func poolName(prefix, version, platform string) string {
+	name := strings.ToLower(fmt.Sprintf("%s-%s-%s", prefix, version, platform))
+	name = strings.ReplaceAll(name, ".", "-")
+
+	return invalidResourceNameChars.ReplaceAllString(name, "-")
+}
+
+// SelectPools returns the pools on one of the platforms, or every pool when no platform is set
+func SelectPools(pools []orchestrate.PoolFlags, platforms []string) []orchestrate.PoolFlags {
+	if len(platforms) == 0 {
+		return pools
+	}
+
+	var selected []orchestrate.PoolFlags
+	for _, pool := range pools {
+		for _, platform := range platforms {
+			if pool.Platform == platform {
+				selected = append(selected, pool)
+				break
+			}
+		}
+	}
+
+	return selected
+}
+
+// RouteBundles returns the targets of a run over the pools: each bundle on every pool whose OpenShift version is
+// supported by the bundle. The bundles with an invalid range of versions are audited on every pool, while those
+// supporting none of the pool versions are not audited.
+func RouteBundles(bundles []index.Bundle, pools []orchestrate.PoolFlags) []state.Target {
+	var targets []state.Target
+	for _, bundle := range bundles {
+		versionRange, err := release.ParseOpenShiftVersions(bundle.OpenShiftVersions)
+		if err != nil {
+			log.Warnf("Bundle %s is audited on every pool as its %s annotation is invalid: %v\n",
+				bundle.Name, index.OpenShiftVersionsAnnotation, err)
+		}
+
+		routed := false
+		for _, pool := range pools {
+			if versionRange.Contains(pool.OpenShift) {
+				targets = append(targets, state.Target{Bundle: bundle, PoolName: pool.Name})
+				routed = true
+			}
+		}
+
+		if !routed {
+			log.Warnf("Bundle %s is not audited as no pool runs an OpenShift version it supports (%s)\n",
+				bundle.Name, bundle.OpenShiftVersions)
+		}
+	}
+
+	return targets
+}
@@ -0,0 +1,16 @@
+package matrix
+
+import (
+	"encoding/json"
+)
+
+// Matrix is the file describing the ClusterPools of a run, one per OpenShift version and platform
+type Matrix struct {
+	// Name prefixes the names of the pools, <name>-<version>-<platform>. The pool name of the flags when empty.
+	Name string `json:"name"`
+	// OpenShift are the versions of the pools, e.g. "4.10"
+	OpenShift []string `json:"openshift"`
+	// Platforms hold the pool settings of each platform, e.g. platform, region and credentials, set on top of
+	// the pool flags of the command
+	Platforms []json.RawMessage `json:"platforms"`
+}
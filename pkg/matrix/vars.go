@@ -0,0 +1,7 @@
+package matrix
+
+import (
+	"regexp"
+)
+
+var invalidResourceNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
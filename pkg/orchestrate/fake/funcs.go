@@ -0,0 +1,219 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	hivev1api "github.com/openshift/hive/apis/hive/v1"
+	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
+	hivev1fake "github.com/openshift/hive/pkg/client/clientset/versioned/fake"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// NewHiveClient returns a Hive clientset backed by an in-memory tracker holding the given objects
+func NewHiveClient(objects ...runtime.Object) *hivev1fake.Clientset {
+	return hivev1fake.NewSimpleClientset(objects...)
+}
+
+// NewK8sClient returns a Kubernetes clientset backed by an in-memory tracker holding the given objects
+func NewK8sClient(objects ...runtime.Object) *k8sfake.Clientset {
+	return k8sfake.NewSimpleClientset(objects...)
+}
+
+// AuditClient returns a replacement of orchestrate.NewAuditClient which ignores the kubeconfig of the claimed
+// cluster and always returns the given client
+func AuditClient(client kubernetes.Interface) func(kubeconfig []byte) (kubernetes.Interface, error) {
+	return func(kubeconfig []byte) (kubernetes.Interface, error) {
+		return client, nil
+	}
+}
+
+// WaitForWatch blocks until a watch was opened on the resource, e.g. "clusterclaims" or "jobs", so that the
+// changes made next are received by the code under test. The fake clientsets do not replay the current state of
+// the objects to new watches.
+func WaitForWatch(ctx context.Context, client *k8stesting.Fake, resource string) error {
+	err := wait.PollImmediateUntil(watchPollInterval, func() (bool, error) {
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "watch" && action.GetResource().Resource == resource {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}, ctx.Done())
+	if err != nil {
+		return fmt.Errorf("no watch was opened on %s : %s", resource, err)
+	}
+
+	return nil
+}
+
+// SetClusterPoolReady reports every cluster of the ClusterPool as provisioned and its running clusters as ready
+func SetClusterPoolReady(ctx context.Context, hvclient hivev1client.Interface, namespace, name string) error {
+	pool, err := hvclient.HiveV1().ClusterPools(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get ClusterPool %s : %s", name, err)
+	}
+
+	pool.Status.Size = pool.Spec.Size
+	pool.Status.Ready = pool.Spec.RunningCount
+	pool.Status.Standby = pool.Spec.Size - pool.Spec.RunningCount
+
+	if _, err := hvclient.HiveV1().ClusterPools(namespace).UpdateStatus(ctx, pool, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update ClusterPool %s : %s", name, err)
+	}
+
+	return nil
+}
+
+// SetClusterClaimRunning assigns the cluster of the ClusterDeployment namespace to the ClusterClaim and reports
+// the cluster as running
+func SetClusterClaimRunning(ctx context.Context, hvclient hivev1client.Interface, namespace, name,
+	cdNamespace string) error {
+	return setClusterClaim(ctx, hvclient, namespace, name, cdNamespace, []hivev1api.ClusterClaimCondition{
+		claimCondition(hivev1api.ClusterClaimPendingCondition, corev1.ConditionFalse, "ClusterClaimed",
+			"Cluster claimed"),
+		claimCondition(hivev1api.ClusterRunningCondition, corev1.ConditionTrue, "Running",
+			"Cluster is running"),
+	})
+}
+
+// SetClusterClaimPending reports the ClusterClaim as waiting for a cluster with the given reason, e.g.
+// "NoClusters"
+func SetClusterClaimPending(ctx context.Context, hvclient hivev1client.Interface, namespace, name,
+	reason string) error {
+	return setClusterClaim(ctx, hvclient, namespace, name, "", []hivev1api.ClusterClaimCondition{
+		claimCondition(hivev1api.ClusterClaimPendingCondition, corev1.ConditionTrue, reason,
+			"Waiting for a cluster from the pool"),
+	})
+}
+
+func setClusterClaim(ctx context.Context, hvclient hivev1client.Interface, namespace, name, cdNamespace string,
+	conditions []hivev1api.ClusterClaimCondition) error {
+	claim, err := hvclient.HiveV1().ClusterClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get ClusterClaim %s : %s", name, err)
+	}
+
+	// the spec and the status are updated separately, as API servers ignore the status of the updates of the
+	// resources with a status subresource
+	claim.Spec.Namespace = cdNamespace
+	claim, err = hvclient.HiveV1().ClusterClaims(namespace).Update(ctx, claim, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to update ClusterClaim %s : %s", name, err)
+	}
+
+	claim.Status.Conditions = conditions
+	if _, err := hvclient.HiveV1().ClusterClaims(namespace).UpdateStatus(ctx, claim, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update the status of ClusterClaim %s : %s", name, err)
+	}
+
+	return nil
+}
+
+func claimCondition(conditionType hivev1api.ClusterClaimConditionType, status corev1.ConditionStatus, reason,
+	message string) hivev1api.ClusterClaimCondition {
+	now := metav1.Now()
+
+	return hivev1api.ClusterClaimCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	}
+}
+
+// CompleteJob sets the Complete condition of the Job, as the Job controller does when its pod succeeded
+func CompleteJob(ctx context.Context, k8sclient kubernetes.Interface, namespace, name string) error {
+	return setJobCondition(ctx, k8sclient, namespace, name, batchv1.JobComplete, "", "")
+}
+
+// FailJob sets the Failed condition of the Job with the given reason, e.g. "BackoffLimitExceeded" or
+// "DeadlineExceeded"
+func FailJob(ctx context.Context, k8sclient kubernetes.Interface, namespace, name, reason, message string) error {
+	return setJobCondition(ctx, k8sclient, namespace, name, batchv1.JobFailed, reason, message)
+}
+
+func setJobCondition(ctx context.Context, k8sclient kubernetes.Interface, namespace, name string,
+	conditionType batchv1.JobConditionType, reason, message string) error {
+	job, err := k8sclient.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get Job %s : %s", name, err)
+	}
+
+	now := metav1.Now()
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+
+	if conditionType == batchv1.JobComplete {
+		job.Status.Succeeded = 1
+		job.Status.CompletionTime = &now
+	} else {
+		job.Status.Failed = 1
+	}
+
+	if _, err := k8sclient.BatchV1().Jobs(namespace).UpdateStatus(ctx, job, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update Job %s : %s", name, err)
+	}
+
+	return nil
+}
+
+// AddWaitingPod creates a pod of the Job whose container waits with the given reason, e.g. "ImagePullBackOff",
+// so that a failure of the Job is classified as an infrastructure one
+func AddWaitingPod(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName, reason string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName + "-" + podNameSuffix,
+			Namespace: namespace,
+			Labels:    map[string]string{"job-name": jobName},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: jobName,
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: reason},
+				},
+			}},
+		},
+	}
+
+	if _, err := k8sclient.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to create pod of Job %s : %s", jobName, err)
+	}
+
+	return nil
+}
+
+// DeleteJobPods deletes the pods of the Job, as the job controller does when the Job exceeds its deadline
+func DeleteJobPods(ctx context.Context, k8sclient kubernetes.Interface, namespace, jobName string) error {
+	pods, err := k8sclient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list the pods of Job %s : %s", jobName, err)
+	}
+
+	for _, pod := range pods.Items {
+		if err := k8sclient.CoreV1().Pods(namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("unable to delete pod %s of Job %s : %s", pod.Name, jobName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package fake
+
+import (
+	"time"
+)
+
+// watchPollInterval is how often the actions of a fake clientset are checked for a new watch
+const watchPollInterval = 10 * time.Millisecond
+
+const podNameSuffix = "fake"
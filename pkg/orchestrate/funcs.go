@@ -1,50 +1,76 @@
 package orchestrate
 
 import (
-	"bufio"
+	"audit-tool-orchestrator/pkg/artifacts"
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/release"
+	"audit-tool-orchestrator/pkg/state"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	hivev1api "github.com/openshift/hive/apis/hive/v1"
+	"github.com/openshift/hive/apis/hive/v1/aws"
+	"github.com/openshift/hive/apis/hive/v1/azure"
+	"github.com/openshift/hive/apis/hive/v1/gcp"
+	"github.com/openshift/hive/apis/hive/v1/ibmcloud"
 	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
 	log "github.com/sirupsen/logrus"
+	"io"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
-	"net/http"
 	"os"
-	"regexp"
+	"path"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
 	"time"
 )
 
-func GetHiveClient() *hivev1client.Clientset {
-	// create hive client
+// GetHiveClient returns the client of the Hive cluster from the kubeconfig set in OPENSHIFT_KUBECONFIG
+func GetHiveClient() (hivev1client.Interface, error) {
 	cfg, err := clientcmd.BuildConfigFromFlags("", os.Getenv("OPENSHIFT_KUBECONFIG"))
 	if err != nil {
-		log.Printf("Unable to build config from flags: %v\n", err)
+		return nil, fmt.Errorf("unable to build config from flags : %s", err)
 	}
 
 	clientset, err := hivev1client.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the Hive client : %s", err)
+	}
 
-	return clientset
+	return clientset, nil
 }
 
-func GetK8sClient() *kubernetes.Clientset {
-	// create k8s client
+// GetK8sClient returns the Kubernetes client of the Hive cluster from the kubeconfig set in OPENSHIFT_KUBECONFIG
+func GetK8sClient() (kubernetes.Interface, error) {
 	cfg, err := clientcmd.BuildConfigFromFlags("", os.Getenv("OPENSHIFT_KUBECONFIG"))
 	if err != nil {
-		log.Errorf("Unable to build config from flags: %v\n", err)
+		return nil, fmt.Errorf("unable to build config from flags : %s", err)
 	}
+
 	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the Kubernetes client : %s", err)
+	}
 
-	return clientset
+	return clientset, nil
 }
 
-/*func GetAuditClient(kubeconfig *corev1.Secret) *kubernetes.Clientset {
+/*func GetAuditClient(kubeconfig *corev1.Secret) kubernetes.Interface {
 	// take secret and get bytes to pass to RESTConfigFromKubeConfig
 
 	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
@@ -56,92 +82,46 @@ func GetK8sClient() *kubernetes.Clientset {
 	return clientset
 }*/
 
-func K8sClientForAudit(kubeconfig []byte) *kubernetes.Clientset {
+// K8sClientForAudit returns the Kubernetes client of the cluster under test from its kubeconfig
+func K8sClientForAudit(kubeconfig []byte) (kubernetes.Interface, error) {
 	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
 	if err != nil {
-		log.Errorf("Unable to build config from kubeconfig: %v\n", err)
+		return nil, fmt.Errorf("unable to build config from kubeconfig : %s", err)
 	}
-	clientset, err := kubernetes.NewForConfig(cfg)
-
-	return clientset
-}
 
-func GetOpenShiftVersions(flags PoolFlags) string {
-	resp, err := http.Get("https://mirror.openshift.com/pub/openshift-v4/clients/ocp/stable-" + flags.OpenShift + "/release.txt")
-	if err != nil {
-		log.Errorf("Unable to get stable OpenShift version from mirror.openshift.com: %v\n", err)
-	}
-	scanner := bufio.NewScanner(resp.Body)
-	r, err := regexp.Compile(`^Name:\s*(\d+\.\d+\.\d+)`)
+	clientset, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		log.Errorf("Unable to read the response body from mirror.openshift.com: %v\n", err)
+		return nil, fmt.Errorf("unable to create the Kubernetes client of the cluster under test : %s", err)
 	}
 
-	for scanner.Scan() {
-		if r.MatchString(scanner.Text()) {
-			scanResult := r.FindStringSubmatch(scanner.Text())
-			return scanResult[1]
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Errorf("%v\n", err)
-		return "Error getting OpenShift version."
-	}
-
-	return "Unable to get OpenShift version."
+	return clientset, nil
+}
 
-	// TODO: next two commented blocks for reference only remove when binary is ready
-	/*ctx := context.Background()
-	clusterImageSets, err := hvclient.HiveV1().ClusterImageSets().List(ctx, metav1.ListOptions{})
+func WaitForSuccessfulClusterPool(ctx context.Context, hvclient hivev1client.Interface,
+	pool *hivev1api.ClusterPool) (string, error) {
+	// the pool may be ready already, in which case the watch would not send any event
+	current, err := hvclient.HiveV1().ClusterPools(pool.Namespace).Get(ctx, pool.Name, metav1.GetOptions{})
 	if err != nil {
-		log.Errorf("Unable to get ClusterImageSets: %v\n", err)
+		return "Pool Not Ready", fmt.Errorf("unable to get the ClusterPool %s : %s", pool.Name, err)
 	}
 
-	var cisNames []string
-
-	for _, cis := range clusterImageSets.Items {
-		cisNames = append(cisNames, "v"+strings.Split(cis.Name, "-")[1])
+	if clusterPoolReady(current) {
+		return "Pool Ready", nil
 	}
 
-	semver.Sort(cisNames)
-
-	log.Info(cisNames)*/
-
-	/*
-		    from distutils.version import StrictVersion
-
-			def get_openshift_versions():
-			    payload = requests.get(
-			        "https://quay.io/api/v1/repository/openshift-release-dev/ocp-release?includeTags=true").json()
-			    versions = jq.compile(".tags|with_entries(select(.key|match(\"x86_64\")))|keys").input(payload).first()
-			    pattern = ".*(hotfix|assembly|art|fc|rc|nightly|bad).*"
-			    images = []
-			    selectable_versions = []
-			    filtered = [version for version in versions if not re.match(pattern, version)]
-			    for version in filtered:
-			        release = version.split("-")
-			        image = release[0]
-			        images.append(image)
-			    images.sort(key=StrictVersion, reverse=True)
-			    for image in images:
-			        selectable_versions.append((image, "ocp-" + image))
-			    return selectable_versions
-	*/
-}
-
-func WaitForSuccessfulClusterPool(hvclient *hivev1client.Clientset, pool *hivev1api.ClusterPool) (string, error) {
-	ctx := context.Background()
 	selector := fields.SelectorFromSet(map[string]string{"metadata.name": pool.Name})
 	var wi watch.Interface
 
-	err := wait.ExponentialBackoff(
+	err = wait.ExponentialBackoff(
 		wait.Backoff{Steps: 10, Duration: 10 * time.Second, Factor: 2},
 		func() (bool, error) {
 			var err error
 			cci := hvclient.HiveV1().ClusterPools(pool.Namespace)
 
-			wi, err = cci.Watch(ctx, metav1.ListOptions{FieldSelector: selector.String()})
+			wi, err = cci.Watch(ctx, metav1.ListOptions{
+				FieldSelector:   selector.String(),
+				ResourceVersion: current.ResourceVersion,
+			})
 			if err != nil {
 				log.Error(err)
 				return false, nil
@@ -152,49 +132,79 @@ func WaitForSuccessfulClusterPool(hvclient *hivev1client.Clientset, pool *hivev1
 	)
 
 	if err != nil {
-		log.WithError(err).Fatal("failed to create watch for ClusterPool")
-		return "Pool Not Ready", err
+		return "Pool Not Ready", fmt.Errorf("unable to create the watch for ClusterPool %s : %s", pool.Name, err)
 	}
+	defer wi.Stop()
+
+	for {
+		event, ok := nextEvent(ctx, wi)
+		if !ok {
+			break
+		}
+
+		if err := watchEventError(event); err != nil {
+			return "Pool Not Ready", fmt.Errorf("unable to watch the ClusterPool %s : %s", pool.Name, err)
+		}
 
-	for event := range wi.ResultChan() {
 		clusterPool, ok := event.Object.(*hivev1api.ClusterPool)
 		if !ok {
-			log.WithField("object-type", fmt.Sprintf("%T", event.Object)).Fatal("received an unexpected object from Watch")
+			return "Pool Not Ready", fmt.Errorf("received an unexpected object from the ClusterPool watch : %T",
+				event.Object)
 		}
 
-		log.Infof("ClusterPool event received: %v\n", clusterPool.Status)
+		// fake clientsets do not filter the events with the field selector
+		if clusterPool.Name != pool.Name {
+			continue
+		}
 
-		poolStatusReady := clusterPool.Status.Ready
-		poolStatusSize := clusterPool.Status.Size
-		poolSpecRunning := clusterPool.Spec.RunningCount
-		poolSpecSize := clusterPool.Spec.Size
+		if event.Type == watch.Deleted {
+			return "Pool Not Ready", fmt.Errorf("ClusterPool %s was deleted", pool.Name)
+		}
 
-		if poolStatusReady == poolSpecRunning && poolStatusSize == poolSpecSize {
-			_, err = hvclient.HiveV1().ClusterPools(pool.Namespace).Get(ctx, pool.Name, metav1.GetOptions{})
-			if err != nil {
-				log.Errorf("Unable to get the ClusterPool under watch: %v\n", err)
-				return "Pool Not Ready", err
-			}
+		log.Infof("ClusterPool event received: %v\n", clusterPool.Status)
 
-			break
+		if clusterPoolReady(clusterPool) {
+			return "Pool Ready", nil
 		}
 	}
 
-	return "Pool Ready", err
+	if ctx.Err() != nil {
+		return "Pool Not Ready", ctx.Err()
+	}
+
+	return "Pool Not Ready", fmt.Errorf("watch for ClusterPool %s closed before it was ready", pool.Name)
+}
+
+// clusterPoolReady tells whether the ClusterPool has all of its clusters provisioned and the running ones ready
+func clusterPoolReady(pool *hivev1api.ClusterPool) bool {
+	return pool.Status.Ready == pool.Spec.RunningCount && pool.Status.Size == pool.Spec.Size
 }
 
-func WaitForSuccessfulClusterClaim(hvclient *hivev1client.Clientset, claim *hivev1api.ClusterClaim) (string, error) {
-	ctx := context.Background()
+func WaitForSuccessfulClusterClaim(ctx context.Context, hvclient hivev1client.Interface,
+	claim *hivev1api.ClusterClaim) (string, error) {
+	// the claim may be fulfilled already, in which case the watch would not send any event
+	current, err := hvclient.HiveV1().ClusterClaims(claim.Namespace).Get(ctx, claim.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to get the ClusterClaim %s : %s", claim.Name, err)
+	}
+
+	if clusterClaimRunning(current) {
+		return current.Spec.Namespace, nil
+	}
+
 	selector := fields.SelectorFromSet(map[string]string{"metadata.name": claim.Name})
 	var wi watch.Interface
 
-	err := wait.ExponentialBackoff(
+	err = wait.ExponentialBackoff(
 		wait.Backoff{Steps: 10, Duration: 10 * time.Second, Factor: 2},
 		func() (bool, error) {
 			var err error
 			cci := hvclient.HiveV1().ClusterClaims(claim.Namespace)
 
-			wi, err = cci.Watch(ctx, metav1.ListOptions{FieldSelector: selector.String()})
+			wi, err = cci.Watch(ctx, metav1.ListOptions{
+				FieldSelector:   selector.String(),
+				ResourceVersion: current.ResourceVersion,
+			})
 			if err != nil {
 				log.Error(err)
 				return false, nil
@@ -205,88 +215,1251 @@ func WaitForSuccessfulClusterClaim(hvclient *hivev1client.Clientset, claim *hive
 	)
 
 	if err != nil {
-		log.WithError(err).Fatal("failed to create watch for ClusterClaim")
+		return "", fmt.Errorf("unable to create the watch for ClusterClaim %s : %s", claim.Name, err)
 	}
+	defer wi.Stop()
+
+	for {
+		event, ok := nextEvent(ctx, wi)
+		if !ok {
+			break
+		}
 
-	var watchedClaim *hivev1api.ClusterClaim
+		if err := watchEventError(event); err != nil {
+			return "", fmt.Errorf("unable to watch the ClusterClaim %s : %s", claim.Name, err)
+		}
 
-	for event := range wi.ResultChan() {
 		clusterClaim, ok := event.Object.(*hivev1api.ClusterClaim)
 		if !ok {
-			log.WithField("object-type", fmt.Sprintf("%T", event.Object)).Fatal("received an unexpected object from Watch")
+			return "", fmt.Errorf("received an unexpected object from the ClusterClaim watch : %T", event.Object)
+		}
+
+		// fake clientsets do not filter the events with the field selector
+		if clusterClaim.Name != claim.Name {
+			continue
+		}
+
+		if event.Type == watch.Deleted {
+			return "", fmt.Errorf("ClusterClaim %s was deleted before the cluster was running", claim.Name)
 		}
 
 		log.Infof("ClusterClaim event received: %v\n", clusterClaim.Status.Conditions)
 
-		var pendingStatus, clusterRunningStatus corev1.ConditionStatus
+		if clusterClaimRunning(clusterClaim) {
+			return clusterClaim.Spec.Namespace, nil
+		}
+	}
 
-		for _, clusterClaimCondition := range clusterClaim.Status.Conditions {
-			if clusterClaimCondition.Type == "Pending" {
-				pendingStatus = clusterClaimCondition.Status
-			}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
 
-			if clusterClaimCondition.Type == "ClusterRunning" {
-				clusterRunningStatus = clusterClaimCondition.Status
+	return "", fmt.Errorf("watch for ClusterClaim %s closed before the cluster was running", claim.Name)
+}
+
+// clusterClaimRunning tells whether the ClusterClaim was assigned a cluster and the cluster is running
+func clusterClaimRunning(claim *hivev1api.ClusterClaim) bool {
+	var pendingStatus, clusterRunningStatus corev1.ConditionStatus
+
+	for _, clusterClaimCondition := range claim.Status.Conditions {
+		if clusterClaimCondition.Type == hivev1api.ClusterClaimPendingCondition {
+			pendingStatus = clusterClaimCondition.Status
+		}
+
+		if clusterClaimCondition.Type == hivev1api.ClusterRunningCondition {
+			clusterRunningStatus = clusterClaimCondition.Status
+		}
+	}
+
+	return pendingStatus == corev1.ConditionFalse && clusterRunningStatus == corev1.ConditionTrue
+}
+
+// nextEvent returns the next event of the watch, or false once the watch closed or the context is done
+func nextEvent(ctx context.Context, wi watch.Interface) (watch.Event, bool) {
+	select {
+	case <-ctx.Done():
+		return watch.Event{}, false
+	case event, ok := <-wi.ResultChan():
+		return event, ok
+	}
+}
+
+// watchEventError returns the error sent by the API server on a watch, if any
+func watchEventError(event watch.Event) error {
+	if event.Type != watch.Error {
+		return nil
+	}
+
+	return apierrors.FromObject(event.Object)
+}
+
+// WaitForAuditJob waits for the audit Job to finish and returns its final status. When the Job has an active
+// deadline it is waited for no longer than the deadline and a grace period; a closed watch is opened again.
+func WaitForAuditJob(ctx context.Context, k8sclient kubernetes.Interface,
+	job *batchv1.Job) (JobStatus, error) {
+	selector := fields.SelectorFromSet(map[string]string{"metadata.name": job.Name})
+	status := JobStatus{Result: AuditError}
+
+	if job.Spec.ActiveDeadlineSeconds != nil {
+		var cancel context.CancelFunc
+		deadline := time.Duration(*job.Spec.ActiveDeadlineSeconds)*time.Second + jobDeadlineGrace
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	pods := observeJobPods(ctx, k8sclient, job)
+	defer pods.stop()
+
+	for {
+		// the Job may have finished already, in which case the watch would not send any event
+		current, err := k8sclient.BatchV1().Jobs(job.Namespace).Get(ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("unable to get the Job %s : %s", job.Name, err)
+			status.Message = err.Error()
+			return status, err
+		}
+
+		if final, finished := JobFinalStatus(current); finished {
+			if final.Result == batchv1.JobFailed {
+				classifyJobFailure(ctx, k8sclient, current, pods, &final)
 			}
+			return final, nil
 		}
 
-		if pendingStatus == "False" && clusterRunningStatus == "True" {
-			watchedClaim, err = hvclient.HiveV1().ClusterClaims(claim.Namespace).Get(ctx, claim.Name, metav1.GetOptions{})
-			if err != nil {
-				log.Errorf("Unable to get the ClusterClaim under watch: %v\n", err)
+		var wi watch.Interface
+
+		err = wait.ExponentialBackoff(
+			wait.Backoff{Steps: 10, Duration: 10 * time.Second, Factor: 2},
+			func() (bool, error) {
+				var err error
+				cci := k8sclient.BatchV1().Jobs(job.Namespace)
+
+				wi, err = cci.Watch(ctx, metav1.ListOptions{
+					FieldSelector:   selector.String(),
+					ResourceVersion: current.ResourceVersion,
+				})
+				if err != nil {
+					log.Error(err)
+					return false, nil
+				}
+
+				return true, nil
+			},
+		)
+
+		if err != nil {
+			log.WithError(err).Error("failed to create watch for Job")
+			status.Message = err.Error()
+			return status, err
+		}
+
+		for {
+			event, ok := nextEvent(ctx, wi)
+			if !ok {
+				break
 			}
-			break
+
+			if err := watchEventError(event); err != nil {
+				log.Warnf("Watch for Job %s returned an error: %v\n", job.Name, err)
+				break
+			}
+
+			auditJob, ok := event.Object.(*batchv1.Job)
+			if !ok {
+				log.WithField("object-type", fmt.Sprintf("%T", event.Object)).Warn("received an unexpected object from Watch")
+				break
+			}
+
+			if auditJob.Name != job.Name {
+				continue
+			}
+
+			if event.Type == watch.Deleted {
+				wi.Stop()
+				err = fmt.Errorf("Job %s was deleted before it finished", job.Name)
+				status.Message = err.Error()
+				return status, err
+			}
+
+			log.Infof("Job event received: %v\n", auditJob.Status.Conditions)
+
+			if final, finished := JobFinalStatus(auditJob); finished {
+				wi.Stop()
+				if final.Result == batchv1.JobFailed {
+					classifyJobFailure(ctx, k8sclient, auditJob, pods, &final)
+				}
+				return final, nil
+			}
+		}
+		wi.Stop()
+
+		if ctx.Err() != nil {
+			status.Message = fmt.Sprintf("Job %s did not finish in time : %s", job.Name, ctx.Err())
+			return status, ctx.Err()
+		}
+
+		log.Debugf("Watch for Job %s closed before it finished, watching it again\n", job.Name)
+	}
+}
+
+// JobFinalStatus returns the status of the Job from its Complete or Failed condition and whether it has finished
+func JobFinalStatus(job *batchv1.Job) (JobStatus, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		if condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed {
+			return JobStatus{
+				Result:  condition.Type,
+				Reason:  condition.Reason,
+				Message: condition.Message,
+			}, true
 		}
 	}
 
-	return watchedClaim.Spec.Namespace, nil
+	return JobStatus{Result: AuditError}, false
 }
 
-func WaitForAuditJob(k8sclient *kubernetes.Clientset, job *batchv1.Job) batchv1.JobConditionType {
-	ctx := context.Background()
-	selector := fields.SelectorFromSet(map[string]string{"metadata.name": job.Name})
-	var wi watch.Interface
-	var auditResult batchv1.JobConditionType
+// classifyJobFailure looks at the pods of the failed Job and marks the status as an infrastructure failure
+// when a pod could not run the audit, e.g. its image could not be pulled or it was evicted from the node. A Job
+// which exceeded its deadline without any of its pods starting failed for infrastructure reasons as well, even
+// though its pods were deleted.
+func classifyJobFailure(ctx context.Context, k8sclient kubernetes.Interface, job *batchv1.Job, observed *jobPods,
+	status *JobStatus) {
+	pods, err := k8sclient.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", job.Name),
+	})
+	if err != nil {
+		log.Warnf("Unable to list the pods of Job %s to find the failure reason: %v\n", job.Name, err)
+		pods = &corev1.PodList{}
+	}
 
-	err := wait.ExponentialBackoff(
-		wait.Backoff{Steps: 10, Duration: 10 * time.Second, Factor: 2},
-		func() (bool, error) {
-			var err error
-			cci := k8sclient.BatchV1().Jobs(job.Namespace)
+	started, waiting := inspectJobPods(pods.Items)
+	if waiting != nil {
+		status.Infrastructure = true
+		status.Reason = waiting.Reason
+		status.Message = waiting.Message
+		return
+	}
 
-			wi, err = cci.Watch(ctx, metav1.ListOptions{FieldSelector: selector.String()})
-			if err != nil {
-				log.Error(err)
-				return false, nil
+	if status.Reason != jobDeadlineExceeded || started {
+		return
+	}
+
+	observed.mu.Lock()
+	defer observed.mu.Unlock()
+	if !observed.observed || observed.started {
+		return
+	}
+
+	status.Infrastructure = true
+	if observed.waiting != nil {
+		status.Reason = observed.waiting.Reason
+		status.Message = observed.waiting.Message
+		return
+	}
+	status.Message = fmt.Sprintf("no pod of Job %s started before its deadline : %s", job.Name, status.Message)
+}
+
+// inspectJobPods returns whether any of the pods started the audit and the status of the first pod which could
+// not run it for infrastructure reasons
+func inspectJobPods(pods []corev1.Pod) (bool, *JobStatus) {
+	started := false
+	for _, pod := range pods {
+		if infrastructurePodReasons[pod.Status.Reason] {
+			return started, &JobStatus{
+				Result:         batchv1.JobFailed,
+				Reason:         pod.Status.Reason,
+				Message:        fmt.Sprintf("pod %s: %s", pod.Name, pod.Status.Message),
+				Infrastructure: true,
+			}
+		}
+
+		containers := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, container := range containers {
+			if container.State.Running != nil || container.State.Terminated != nil {
+				started = true
 			}
 
+			if container.State.Waiting != nil && infrastructureContainerReasons[container.State.Waiting.Reason] {
+				return started, &JobStatus{
+					Result: batchv1.JobFailed,
+					Reason: container.State.Waiting.Reason,
+					Message: fmt.Sprintf("pod %s container %s: %s",
+						pod.Name, container.Name, container.State.Waiting.Message),
+					Infrastructure: true,
+				}
+			}
+		}
+	}
+
+	return started, nil
+}
+
+// observeJobPods checks the pods of the running Job every jobPodsPollInterval and records whether any of them
+// started and the last reason they were waiting for, until it is stopped
+func observeJobPods(ctx context.Context, k8sclient kubernetes.Interface, job *batchv1.Job) *jobPods {
+	ctx, cancel := context.WithCancel(ctx)
+	observed := &jobPods{stop: cancel}
+
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		pods, err := k8sclient.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", job.Name),
+		})
+		if err != nil {
+			log.Debugf("Unable to list the pods of Job %s: %v\n", job.Name, err)
+			return
+		}
+
+		started, waiting := inspectJobPods(pods.Items)
+
+		observed.mu.Lock()
+		defer observed.mu.Unlock()
+		observed.observed = true
+		observed.started = observed.started || started
+		if waiting != nil {
+			observed.waiting = waiting
+		}
+	}, jobPodsPollInterval)
+
+	return observed
+}
+
+// AwaitAuditJob waits for the audit Job and, while it fails for infrastructure reasons, deletes it and creates
+// it again from auditJob, up to retries times. Audit failures are not retried.
+func AwaitAuditJob(ctx context.Context, auditClient kubernetes.Interface, auditJob batchv1.Job, job *batchv1.Job,
+	retries int) (JobStatus, error) {
+	for attempt := 1; ; attempt++ {
+		status, err := WaitForAuditJob(ctx, auditClient, job)
+		if err != nil || !status.Infrastructure || attempt > retries {
+			return status, err
+		}
+
+		log.Warnf("Job %s failed for infrastructure reasons (%s: %s). Retrying %d/%d\n",
+			job.Name, status.Reason, status.Message, attempt, retries)
+
+		job, err = recreateAuditJob(ctx, auditClient, auditJob)
+		if err != nil {
+			return JobStatus{Result: AuditError, Message: err.Error()}, err
+		}
+	}
+}
+
+// recreateAuditJob deletes the audit Job with its pods and creates it again
+func recreateAuditJob(ctx context.Context, auditClient kubernetes.Interface, auditJob batchv1.Job) (*batchv1.Job, error) {
+	jobs := auditClient.BatchV1().Jobs(auditJob.Namespace)
+
+	propagation := metav1.DeletePropagationBackground
+	err := jobs.Delete(ctx, auditJob.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to delete Job %s : %s", auditJob.Name, err)
+	}
+
+	err = wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+		_, err := jobs.Get(ctx, auditJob.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
 			return true, nil
+		}
+		return false, nil
+	}, ctx.Done())
+	if err != nil {
+		return nil, fmt.Errorf("unable to wait for Job %s to be deleted : %s", auditJob.Name, err)
+	}
+
+	job, err := jobs.Create(ctx, &auditJob, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Job %s : %s", auditJob.Name, err)
+	}
+
+	return job, nil
+}
+
+// SetPlatform returns the Hive platform for the ClusterPool; defaults to AWS when the platform is not known
+func SetPlatform(platform string, flags PoolFlags) hivev1api.Platform {
+	switch platform {
+	case "aws":
+		aws := &aws.Platform{
+			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
+			Region:               flags.Region,
+		}
+
+		return hivev1api.Platform{AWS: aws}
+	case "azure":
+		azure := &azure.Platform{
+			CredentialsSecretRef:        corev1.LocalObjectReference{Name: flags.Credentials},
+			Region:                      flags.Region,
+			BaseDomainResourceGroupName: flags.AzureBaseDomainResourceGroupName,
+			CloudName:                   flags.AzureCloudName,
+		}
+
+		return hivev1api.Platform{Azure: azure}
+	case "gcp":
+		gcp := &gcp.Platform{
+			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
+			Region:               flags.Region,
+		}
+
+		return hivev1api.Platform{GCP: gcp}
+	case "ibm":
+		ibm := &ibmcloud.Platform{
+			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
+			AccountID:            flags.IBMAccountID,
+			CISInstanceCRN:       flags.IBMCISInstanceCRN,
+			Region:               flags.Region,
+		}
+
+		return hivev1api.Platform{IBMCloud: ibm}
+	}
+
+	return hivev1api.Platform{
+		AWS: &aws.Platform{
+			CredentialsSecretRef: corev1.LocalObjectReference{Name: flags.Credentials},
+			Region:               flags.Region,
 		},
-	)
+	}
+}
+
+// NewClusterPool builds the ClusterPool resource described by the pool flags, installing the clusters with the
+// ClusterImageSet
+func NewClusterPool(flags PoolFlags, imageSetName string) hivev1api.ClusterPool {
+	return hivev1api.ClusterPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      flags.Name,
+			Namespace: flags.Namespace,
+		},
+		Spec: hivev1api.ClusterPoolSpec{
+			Platform:                       SetPlatform(flags.Platform, flags),
+			PullSecretRef:                  &corev1.LocalObjectReference{Name: flags.ImagePullSecret},
+			Size:                           flags.Size,
+			RunningCount:                   flags.Running,
+			BaseDomain:                     flags.BaseDomain,
+			ImageSetRef:                    hivev1api.ClusterImageSetReference{Name: imageSetName},
+			InstallConfigSecretTemplateRef: &corev1.LocalObjectReference{Name: flags.InstallConfig},
+			SkipMachinePools:               true,
+		},
+	}
+}
+
+// EnsureClusterPool creates the ClusterPool when it does not exist yet and waits for it to be ready
+func EnsureClusterPool(ctx context.Context, hvclient hivev1client.Interface, flags PoolFlags) (*hivev1api.ClusterPool, error) {
+	pool, err := hvclient.HiveV1().ClusterPools(flags.Namespace).Get(ctx, flags.Name, metav1.GetOptions{})
+	if err == nil {
+		log.Infof("ClusterPool %s already exists.\n", flags.Name)
+		return pool, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get ClusterPool %s : %s", flags.Name, err)
+	}
+
+	imageSetName, err := release.EnsureClusterImageSet(ctx, hvclient, flags.OpenShift, flags.ReleaseImage,
+		flags.CreateImageSet)
+	if err != nil {
+		return nil, err
+	}
 
+	cp := NewClusterPool(flags, imageSetName)
+	pool, err = hvclient.HiveV1().ClusterPools(flags.Namespace).Create(ctx, &cp, metav1.CreateOptions{})
 	if err != nil {
-		log.WithError(err).Fatal("failed to create watch for Job")
-		auditResult = "Error"
-		return auditResult
+		return nil, fmt.Errorf("unable to create ClusterPool %s : %s", flags.Name, err)
 	}
 
-	var auditJobStatus batchv1.JobConditionType
+	if _, err := WaitForSuccessfulClusterPool(ctx, hvclient, pool); err != nil {
+		return nil, err
+	}
 
-	for event := range wi.ResultChan() {
-		auditJob, ok := event.Object.(*batchv1.Job)
-		if !ok {
-			log.WithField("object-type", fmt.Sprintf("%T", event.Object)).Fatal("received an unexpected object from Watch")
+	return pool, nil
+}
+
+// IsValidPlatform reports whether ClusterPools can be created on the platform
+func IsValidPlatform(platform string) bool {
+	for _, p := range Platforms {
+		if p == platform {
+			return true
 		}
+	}
+	return false
+}
 
-		log.Infof("Job event received: %v\n", auditJob.Status.Conditions)
+// RunPool returns the pool flags of the pool the bundles of the run are routed to, the pool of the flags when
+// the name is empty
+func (f RunFlags) RunPool(name string) (PoolFlags, error) {
+	if len(name) == 0 {
+		return f.Pool, nil
+	}
 
-		if auditJob.Status.Conditions != nil && (auditJob.Status.Conditions[0].Type == "Complete" || auditJob.Status.Conditions[0].Type == "Failed") {
-			auditJobStatus = auditJob.Status.Conditions[0].Type
-			break
+	for _, pool := range f.Pools {
+		if pool.Name == name {
+			return pool, nil
 		}
 	}
 
-	return auditJobStatus
+	return PoolFlags{}, fmt.Errorf("ClusterPool %s is not one of the pools of the run", name)
+}
+
+// PoolJobName returns the name of the audit Job of the bundle on the pool, which is valid for k8s resources.
+// The pool is kept in the name so the artifacts of the bundle on each pool are saved apart.
+func PoolJobName(bundleName, poolName string) string {
+	if len(poolName) == 0 {
+		return ResourceNameForBundle(bundleName)
+	}
+
+	name := bundleResourceName(bundleName)
+	if keep := maxResourceNameLength - len(poolName) - 1; keep > nameHashLength+1 && len(name) > keep {
+		name = shortenName(name, keep)
+	}
+
+	return SuffixedJobName(strings.TrimRight(name, "-."), poolName)
+}
+
+// NewClusterClaim builds the ClusterClaim resource described by the claim flags
+func NewClusterClaim(flags ClaimFlags) hivev1api.ClusterClaim {
+	return hivev1api.ClusterClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      flags.Name,
+			Namespace: flags.Namespace,
+			Labels:    map[string]string{"bundle-name": flags.BundleName},
+		},
+		Spec: hivev1api.ClusterClaimSpec{
+			ClusterPoolName: flags.PoolName,
+		},
+	}
+}
+
+// ClaimClusterForBundle submits a ClusterClaim and waits for the claimed cluster to be running.
+// It returns the namespace of the ClusterDeployment which fulfilled the claim.
+func ClaimClusterForBundle(ctx context.Context, hvclient hivev1client.Interface, flags ClaimFlags) (string, error) {
+	cc := NewClusterClaim(flags)
+	claim, err := hvclient.HiveV1().ClusterClaims(flags.Namespace).Create(ctx, &cc, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to create ClusterClaim %s : %s", flags.Name, err)
+	}
+
+	log.Infof("ClusterClaim %s submitted. Waiting for Pending and ClusterRunning statuses", flags.Name)
+
+	return WaitForSuccessfulClusterClaim(ctx, hvclient, claim)
+}
+
+// EnsureClusterClaim returns the ClusterClaim and submits it when it does not exist yet
+func EnsureClusterClaim(ctx context.Context, hvclient hivev1client.Interface, flags ClaimFlags) (*hivev1api.ClusterClaim, error) {
+	claim, err := hvclient.HiveV1().ClusterClaims(flags.Namespace).Get(ctx, flags.Name, metav1.GetOptions{})
+	if err == nil {
+		log.Infof("ClusterClaim %s already exists. Waiting for Pending and ClusterRunning statuses", flags.Name)
+		return claim, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get ClusterClaim %s : %s", flags.Name, err)
+	}
+
+	cc := NewClusterClaim(flags)
+	claim, err = hvclient.HiveV1().ClusterClaims(flags.Namespace).Create(ctx, &cc, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ClusterClaim %s : %s", flags.Name, err)
+	}
+
+	log.Infof("ClusterClaim %s submitted. Waiting for Pending and ClusterRunning statuses", flags.Name)
+
+	return claim, nil
+}
+
+// DeleteClusterClaim releases the claimed cluster back to Hive
+func DeleteClusterClaim(ctx context.Context, hvclient hivev1client.Interface, name string, namespace string) error {
+	if err := hvclient.HiveV1().ClusterClaims(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("unable to delete ClusterClaim %s : %s", name, err)
+	}
+
+	log.Infof("ClusterClaim %s deleted.\n", name)
+
+	return nil
+}
+
+// GetClusterUnderTestKubeconfig returns the admin kubeconfig of the cluster created by the ClusterDeployment
+func GetClusterUnderTestKubeconfig(ctx context.Context, hvclient hivev1client.Interface, k8sclient kubernetes.Interface,
+	cdNameNamespace string) ([]byte, error) {
+	clusterDeployment, err := hvclient.HiveV1().ClusterDeployments(cdNameNamespace).Get(ctx, cdNameNamespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get ClusterDeployment %s : %s", cdNameNamespace, err)
+	}
+
+	if clusterDeployment.Spec.ClusterMetadata == nil {
+		return nil, fmt.Errorf("ClusterDeployment %s has no cluster metadata", cdNameNamespace)
+	}
+
+	kubeconfigSecret := clusterDeployment.Spec.ClusterMetadata.AdminKubeconfigSecretRef
+	kubeconfig, err := k8sclient.CoreV1().Secrets(cdNameNamespace).Get(ctx, kubeconfigSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get kubeconfig for cluster under test : %s", err)
+	}
+
+	return kubeconfig.Data["raw-kubeconfig"], nil
+}
+
+// GetClusterVersion returns the OpenShift version installed on the cluster of the ClusterDeployment
+func GetClusterVersion(ctx context.Context, hvclient hivev1client.Interface, cdNameNamespace string) (string, error) {
+	clusterDeployment, err := hvclient.HiveV1().ClusterDeployments(cdNameNamespace).Get(ctx, cdNameNamespace, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to get ClusterDeployment %s : %s", cdNameNamespace, err)
+	}
+
+	if version, ok := clusterDeployment.Labels[clusterVersionLabel]; ok {
+		return version, nil
+	}
+
+	if clusterDeployment.Status.InstallVersion != nil {
+		return *clusterDeployment.Status.InstallVersion, nil
+	}
+
+	return "", fmt.Errorf("ClusterDeployment %s does not report its version", cdNameNamespace)
+}
+
+// PrepareClusterUnderTest adds the kubeconfig, registry pull and, when set, bucket credentials secrets required
+// by the audit Jobs to their namespace
+func PrepareClusterUnderTest(ctx context.Context, auditClient kubernetes.Interface, namespace string,
+	kubeconfig []byte, credentials bucket.Credentials) error {
+	auditKubeconfig := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "kubeconfig",
+		},
+		StringData: map[string]string{"config": string(kubeconfig)},
+		Type:       "Opaque",
+	}
+
+	_, err := auditClient.CoreV1().Secrets(namespace).Create(ctx, &auditKubeconfig, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to add kubeconfig secret to cluster under test : %s", err)
+	}
+
+	// TODO: get from secret
+	registryPullSecret, err := os.ReadFile(os.Getenv("REGISTRY_PULL_SECRET"))
+	if err != nil {
+		return fmt.Errorf("unable to get registry pull secret : %s", err)
+	}
+
+	auditImagePullSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "registry-pull-secret",
+		},
+		StringData: map[string]string{".dockerconfigjson": string(registryPullSecret)},
+		Type:       "kubernetes.io/dockerconfigjson",
+	}
+
+	_, err = auditClient.CoreV1().Secrets(namespace).Create(ctx, &auditImagePullSecret, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to add registry image pull secret to cluster under test : %s", err)
+	}
+
+	// the audit Jobs which do not use the bucket get no credentials
+	if credentials == (bucket.Credentials{}) {
+		return nil
+	}
+
+	return bucket.EnsureSecret(ctx, auditClient, namespace, credentials)
+}
+
+// JobTemplateUsesBucket returns whether the Job template refers to the bucket or its credentials Secret
+func JobTemplateUsesBucket(jobTemplate string) bool {
+	return bucketTemplateFields.MatchString(jobTemplate)
+}
+
+// Artifacts returns where the artifacts of the Jobs of the flags are saved, under the bundle name or else the
+// Job one
+func (f JobFlags) Artifacts() artifacts.Flags {
+	bundleName := f.BundleName
+	if len(bundleName) == 0 {
+		bundleName = f.Name
+	}
+
+	return artifacts.Flags{Dir: f.ArtifactsDir, RunID: f.RunID, BundleName: bundleName,
+		Upload: f.UploadArtifacts, BucketName: f.BucketName, Secure: f.Secure, Credentials: f.Credentials}
+}
+
+// UsesBucket returns whether the Job template of the flags, or the built-in one, uses the bucket
+func (f JobFlags) UsesBucket() (bool, error) {
+	return templateUsesBucket(f.Template)
+}
+
+// UsesBucket returns whether the run stores the audit results in the bucket or its Jobs use it
+func (f RunFlags) UsesBucket() (bool, error) {
+	if len(f.BucketName) > 0 {
+		return true, nil
+	}
+
+	return templateUsesBucket(f.JobTemplate)
+}
+
+// templateUsesBucket returns whether the Job template file, or the built-in one when empty, uses the bucket
+func templateUsesBucket(path string) (bool, error) {
+	if len(path) == 0 {
+		return JobTemplateUsesBucket(DefaultJobTemplate), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("unable to read the Job template %s : %s", path, err)
+	}
+
+	return JobTemplateUsesBucket(string(data)), nil
+}
+
+// NewAuditJob builds the Job which runs the audit tool against the bundle on the cluster under test from
+// the Job template of the flags, or from the built-in capabilities-tool template when none is set
+func NewAuditJob(flags JobFlags) (batchv1.Job, error) {
+	jobTemplate := DefaultJobTemplate
+	if len(flags.Template) > 0 {
+		data, err := os.ReadFile(flags.Template)
+		if err != nil {
+			return batchv1.Job{}, fmt.Errorf("unable to read the Job template %s : %s", flags.Template, err)
+		}
+		jobTemplate = string(data)
+	}
+
+	return RenderJob(jobTemplate, flags)
+}
+
+// RenderJob executes the Job template with the variables of the flags and decodes the resulting Job
+func RenderJob(jobTemplate string, flags JobFlags) (batchv1.Job, error) {
+	var auditJob batchv1.Job
+
+	tmpl, err := template.New("job").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(jobTemplate)
+	if err != nil {
+		return auditJob, fmt.Errorf("unable to parse the Job template : %s", err)
+	}
+
+	var manifest bytes.Buffer
+	err = tmpl.Execute(&manifest, JobTemplateData{
+		Name:         flags.Name,
+		BundleImage:  flags.BundleImage,
+		BundleName:   flags.BundleName,
+		BucketName:   flags.BucketName,
+		BucketSecret: bucket.SecretName,
+		ClaimName:    flags.ClaimName,
+	})
+	if err != nil {
+		return auditJob, fmt.Errorf("unable to render the Job template : %s", err)
+	}
+
+	if err := yaml.UnmarshalStrict(manifest.Bytes(), &auditJob); err != nil {
+		return auditJob, fmt.Errorf("unable to decode the Job rendered from the template : %s", err)
+	}
+
+	if auditJob.Kind != jobKind {
+		return auditJob, fmt.Errorf("the Job template renders a %s instead of a %s", auditJob.Kind, jobKind)
+	}
+
+	if len(auditJob.Name) == 0 {
+		auditJob.Name = flags.Name
+	}
+
+	if len(auditJob.Namespace) == 0 {
+		auditJob.Namespace = DefaultJobNamespace
+	}
+
+	if flags.Deadline > 0 {
+		deadline := int64(flags.Deadline.Seconds())
+		auditJob.Spec.ActiveDeadlineSeconds = &deadline
+	}
+
+	return auditJob, nil
+}
+
+// RunAuditJob creates the audit Job on the cluster under test and waits for it to finish, retrying it on
+// infrastructure failures
+func RunAuditJob(ctx context.Context, auditClient kubernetes.Interface, flags JobFlags) (JobStatus, error) {
+	auditJob, err := NewAuditJob(flags)
+	if err != nil {
+		return JobStatus{Result: AuditError, Message: err.Error()}, err
+	}
+
+	job, err := auditClient.BatchV1().Jobs(auditJob.Namespace).Create(ctx, &auditJob, metav1.CreateOptions{})
+	if err != nil {
+		err = fmt.Errorf("unable to create Job %s : %s", flags.Name, err)
+		return JobStatus{Result: AuditError, Message: err.Error()}, err
+	}
+
+	stopLogs := artifacts.Follow(ctx, auditClient, job.Namespace, job.Name, flags.FollowLogs)
+	status, err := AwaitAuditJob(ctx, auditClient, auditJob, job, flags.Retries)
+	stopLogs()
+
+	artifacts.Save(ctx, auditClient, job.Namespace, job.Name, flags.Artifacts())
+
+	return status, err
+}
+
+// WorkersForPool returns the number of bundles audited at the same time on clusters of the pool
+func WorkersForPool(pool *hivev1api.ClusterPool) int {
+	if pool.Spec.Size > 0 {
+		return int(pool.Spec.Size)
+	}
+
+	if pool.Spec.RunningCount > 0 {
+		return int(pool.Spec.RunningCount)
+	}
+
+	return 1
+}
+
+// AuditRun audits the bundles of the run which have not reached a final result yet.
+// The bundles of each pool are spread across flags.Workers workers, or as many as the size of the pool, each
+// one owning a ClusterClaim for its lifetime and reusing the claimed cluster for its successive bundles. The
+// pools are created when they do not exist yet. The bundles already audited are reported with the result
+// recorded in the state store. Cancelling the context stops the workers and releases their claims.
+func AuditRun(ctx context.Context, hvclient hivev1client.Interface, k8sclient kubernetes.Interface,
+	store *state.Store, runID string, flags RunFlags) ([]BundleAuditResult, error) {
+	bundles, err := store.Bundles(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BundleAuditResult
+	pending := map[string][]state.BundleState{}
+	var poolNames []string
+	for _, bundle := range bundles {
+		if bundle.Finished() {
+			log.Infof("Bundle %s already audited with result %s\n", bundle.BundleName, bundle.Result)
+			pool, _ := flags.RunPool(bundle.PoolName)
+			results = append(results, BundleAuditResult{
+				BundleName:  bundle.BundleName,
+				PackageName: bundle.PackageName,
+				PoolName:    pool.Name,
+				ClaimName:   bundle.ClaimName,
+				Result:      bundle.Result,
+				Reason:      bundle.Reason,
+				Message:     bundle.Message,
+			})
+			continue
+		}
+
+		if _, ok := pending[bundle.PoolName]; !ok {
+			poolNames = append(poolNames, bundle.PoolName)
+		}
+		pending[bundle.PoolName] = append(pending[bundle.PoolName], bundle)
+	}
+
+	// fail before claiming any cluster when the audit Jobs would not reach the bucket
+	var credentials bucket.Credentials
+	usesBucket, err := flags.UsesBucket()
+	if err != nil {
+		return results, err
+	}
+	if len(pending) > 0 && usesBucket {
+		credentials, err = bucket.LoadCredentials(ctx, k8sclient, flags.BucketCredentials)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	report := func(result BundleAuditResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	}
+
+	sort.Strings(poolNames)
+	for _, poolName := range poolNames {
+		poolBundles := pending[poolName]
+
+		poolFlags, err := flags.RunPool(poolName)
+		if err != nil {
+			for _, bundle := range poolBundles {
+				report(BundleAuditResult{BundleName: bundle.BundleName, PackageName: bundle.PackageName,
+					PoolName: poolName, Result: AuditError, Error: err.Error()})
+			}
+			continue
+		}
+
+		prefix := claimPrefix(runID, poolName, flags)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			auditPool(ctx, hvclient, k8sclient, store, flags, poolFlags, prefix, credentials, poolBundles, report)
+		}()
+	}
+
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].BundleName != results[j].BundleName {
+			return results[i].BundleName < results[j].BundleName
+		}
+		return results[i].PoolName < results[j].PoolName
+	})
+
+	if ctx.Err() != nil {
+		return results, fmt.Errorf("run %s interrupted : %s", runID, ctx.Err())
+	}
+
+	return results, nil
+}
+
+// claimPrefix returns the prefix of the names of the ClusterClaims of the workers of the pool. The claims of
+// the pools of a matrix are numbered after the position of the pool in the run so their names stay the same
+// when the run is resumed.
+func claimPrefix(runID, poolName string, flags RunFlags) string {
+	prefix := resourceNamePrefix + runID
+	for i, pool := range flags.Pools {
+		if pool.Name == poolName {
+			return fmt.Sprintf("%s-%d", prefix, i+1)
+		}
+	}
+
+	return prefix
+}
+
+// auditPool ensures the ClusterPool exists and audits the bundles routed to it with workers claiming their
+// clusters from it
+func auditPool(ctx context.Context, hvclient hivev1client.Interface, k8sclient kubernetes.Interface,
+	store *state.Store, flags RunFlags, poolFlags PoolFlags, claimPrefix string, credentials bucket.Credentials,
+	bundles []state.BundleState, report func(BundleAuditResult)) {
+	pool, err := EnsureClusterPool(ctx, hvclient, poolFlags)
+	if err != nil {
+		log.Errorf("Unable to ensure ClusterPool %s: %v\n", poolFlags.Name, err)
+		// the bundles are left without result so they are audited when the run is resumed
+		for _, bundle := range bundles {
+			report(BundleAuditResult{BundleName: bundle.BundleName, PackageName: bundle.PackageName,
+				PoolName: poolFlags.Name, Result: AuditError, Error: err.Error()})
+		}
+		return
+	}
+
+	workers := flags.Workers
+	if workers < 1 {
+		workers = WorkersForPool(pool)
+	}
+	if workers > len(bundles) {
+		workers = len(bundles)
+	}
+
+	queue := make(chan state.BundleState)
+	go func() {
+		defer close(queue)
+		for _, bundle := range bundles {
+			select {
+			case queue <- bundle:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= workers; i++ {
+		wg.Add(1)
+
+		w := worker{
+			claimFlags: ClaimFlags{
+				Name:      fmt.Sprintf("%s-%d", claimPrefix, i),
+				Namespace: poolFlags.Namespace,
+				PoolName:  poolFlags.Name,
+			},
+			hvclient:    hvclient,
+			k8sclient:   k8sclient,
+			store:       store,
+			flags:       flags,
+			credentials: credentials,
+		}
+
+		go func() {
+			defer wg.Done()
+			w.run(ctx, queue, report)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// run audits the bundles received from the queue on the cluster claimed by the worker
+// and releases the claim once the queue is drained or the context is cancelled
+func (w *worker) run(ctx context.Context, queue <-chan state.BundleState, report func(BundleAuditResult)) {
+	defer func() {
+		if !w.claimed {
+			return
+		}
+
+		// the run context may be cancelled already but the claim must be released anyway
+		if err := DeleteClusterClaim(context.Background(), w.hvclient, w.claimFlags.Name,
+			w.claimFlags.Namespace); err != nil {
+			log.Errorf("Unable to release ClusterClaim %s: %v\n", w.claimFlags.Name, err)
+		}
+	}()
+
+	for bundle := range queue {
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Infof("Auditing bundle %s on ClusterClaim %s\n", bundle.BundleName, w.claimFlags.Name)
+		report(w.auditBundle(ctx, bundle))
+	}
+}
+
+// claim submits the ClusterClaim of the worker, when not done yet, and gets a client for the claimed cluster
+func (w *worker) claim(ctx context.Context) error {
+	if w.auditClient != nil {
+		return nil
+	}
+
+	claim, err := EnsureClusterClaim(ctx, w.hvclient, w.claimFlags)
+	if err != nil {
+		return err
+	}
+	w.claimed = true
+
+	cdNameNamespace, err := WaitForSuccessfulClusterClaim(ctx, w.hvclient, claim)
+	if err != nil {
+		return err
+	}
+
+	kubeconfig, err := GetClusterUnderTestKubeconfig(ctx, w.hvclient, w.k8sclient, cdNameNamespace)
+	if err != nil {
+		return err
+	}
+
+	auditClient, err := NewAuditClient(kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	clusterVersion, err := GetClusterVersion(ctx, w.hvclient, cdNameNamespace)
+	if err != nil {
+		log.Warnf("Unable to get the version of the cluster under test: %v\n", err)
+	}
+
+	w.cdNameNamespace = cdNameNamespace
+	w.clusterVersion = clusterVersion
+	w.kubeconfig = kubeconfig
+	w.auditClient = auditClient
+
+	return nil
+}
+
+// prepare adds the secrets required by the audit Jobs to their namespace on the claimed cluster, once per namespace
+func (w *worker) prepare(ctx context.Context, namespace string) error {
+	if w.prepared[namespace] {
+		return nil
+	}
+
+	if err := PrepareClusterUnderTest(ctx, w.auditClient, namespace, w.kubeconfig, w.credentials); err != nil {
+		return err
+	}
+
+	if w.prepared == nil {
+		w.prepared = map[string]bool{}
+	}
+	w.prepared[namespace] = true
+
+	return nil
+}
+
+// auditBundle runs the audit Job for the bundle on the cluster claimed by the worker.
+// The progress is saved in the state store so a resumed run only audits the bundles without a final result.
+func (w *worker) auditBundle(ctx context.Context, bundle state.BundleState) BundleAuditResult {
+	bundle.ClaimName = w.claimFlags.Name
+
+	result := BundleAuditResult{
+		RunID:       bundle.RunID,
+		BundleName:  bundle.BundleName,
+		PackageName: bundle.PackageName,
+		PoolName:    w.claimFlags.PoolName,
+		ClaimName:   bundle.ClaimName,
+		Result:      AuditError,
+	}
+
+	fail := func(err error) BundleAuditResult {
+		result.Error = err.Error()
+		bundle.Result = result.Result
+		bundle.Reason = ""
+		bundle.Message = ""
+		bundle.Error = result.Error
+		bundle.FinishedAt = state.Now()
+		// an interrupted bundle is left without result so it is audited when the run is resumed
+		if ctx.Err() != nil {
+			bundle.Result = ""
+			bundle.FinishedAt = ""
+		} else {
+			w.saveResult(ctx, result)
+		}
+		if err := w.store.UpdateBundle(bundle); err != nil {
+			log.Errorf("Unable to save state of bundle %s: %v\n", bundle.BundleName, err)
+		}
+		return result
+	}
+
+	if err := w.claim(ctx); err != nil {
+		return fail(err)
+	}
+
+	bundle.ClusterDeploymentNamespace = w.cdNameNamespace
+	bundle.ClusterVersion = w.clusterVersion
+	if err := w.store.UpdateBundle(bundle); err != nil {
+		return fail(err)
+	}
+
+	jobFlags := JobFlags{
+		Name:        PoolJobName(bundle.BundleName, bundle.PoolName),
+		BundleImage: bundle.BundleImage,
+		BundleName:  bundle.BundleName,
+		BucketName:  w.flags.BucketName,
+		ClaimName:   w.claimFlags.Name,
+		Template:    w.flags.JobTemplate,
+		Deadline:    w.flags.JobDeadline,
+		Retries:     w.flags.JobRetries,
+		// the artifacts of each run are kept apart
+		ArtifactsDir:    artifacts.RunDir(w.flags.ArtifactsDir, bundle.RunID),
+		UploadArtifacts: w.flags.UploadArtifacts,
+		Secure:          w.flags.Secure,
+		RunID:           bundle.RunID,
+		Credentials:     w.credentials,
+	}
+
+	auditJob, err := NewAuditJob(jobFlags)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := w.prepare(ctx, auditJob.Namespace); err != nil {
+		return fail(err)
+	}
+
+	job, err := EnsureAuditJob(ctx, w.auditClient, auditJob)
+	if err != nil {
+		return fail(err)
+	}
+
+	bundle.JobName = job.Name
+	if len(bundle.StartedAt) == 0 {
+		bundle.StartedAt = job.CreationTimestamp.UTC().Format(time.RFC3339)
+	}
+	if err := w.store.UpdateBundle(bundle); err != nil {
+		return fail(err)
+	}
+
+	status, err := AwaitAuditJob(ctx, w.auditClient, auditJob, job, jobFlags.Retries)
+	// the artifacts tell why the Job could not be awaited, they are saved unless the run is interrupted
+	if ctx.Err() == nil {
+		artifacts.Save(ctx, w.auditClient, job.Namespace, job.Name, jobFlags.Artifacts())
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Result = status.Result
+	result.Reason = status.Reason
+	result.Message = status.Message
+	bundle.Result = status.Result
+	bundle.Reason = status.Reason
+	bundle.Message = status.Message
+	bundle.Error = ""
+	bundle.FinishedAt = state.Now()
+	w.saveResult(ctx, result)
+	if err := w.store.UpdateBundle(bundle); err != nil {
+		log.Errorf("Unable to save state of bundle %s: %v\n", bundle.BundleName, err)
+	}
+
+	return result
+}
+
+// saveResult stores the audit result of the bundle in the bucket, where it is read by orchestrate verify. The
+// results of a bundle audited on several pools are kept apart.
+func (w *worker) saveResult(ctx context.Context, result BundleAuditResult) {
+	if len(w.flags.BucketName) == 0 {
+		return
+	}
+
+	poolName := ""
+	if len(w.flags.Pools) > 1 {
+		poolName = result.PoolName
+	}
+	key := ResultObjectKey(result.RunID, result.BundleName, poolName)
+
+	data, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		log.Errorf("Unable to marshal the audit result of bundle %s: %v\n", result.BundleName, err)
+		return
+	}
+
+	client, err := bucket.NewClientFromCredentials(w.credentials, w.flags.BucketName, w.flags.Secure)
+	if err == nil {
+		err = client.Put(ctx, key, data)
+	}
+	if err != nil {
+		log.Errorf("Unable to save the audit result of bundle %s in the bucket: %v\n", result.BundleName, err)
+	}
+}
+
+// ResultObjectKey returns the key of the object holding the audit result of the bundle in the run, under the
+// pool name when it is set
+func ResultObjectKey(runID, bundleName, poolName string) string {
+	return path.Join(runID, bundleName, poolName, ResultObjectName)
+}
+
+// PrintAuditSummary writes a table with the audit result of each bundle
+func PrintAuditSummary(out io.Writer, results []BundleAuditResult) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "BUNDLE\tPACKAGE\tPOOL\tCLAIM\tRESULT\tREASON\tMESSAGE")
+	for _, result := range results {
+		message := result.Message
+		if len(result.Error) > 0 {
+			message = result.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", result.BundleName, result.PackageName, result.PoolName,
+			result.ClaimName, result.Result, result.Reason, message)
+	}
+
+	return w.Flush()
+}
+
+// EnsureAuditJob returns the audit Job and creates it on the cluster under test when it does not exist yet
+func EnsureAuditJob(ctx context.Context, auditClient kubernetes.Interface, auditJob batchv1.Job) (*batchv1.Job, error) {
+	job, err := auditClient.BatchV1().Jobs(auditJob.Namespace).Get(ctx, auditJob.Name, metav1.GetOptions{})
+	if err == nil {
+		log.Infof("Job %s already exists on the cluster under test.\n", auditJob.Name)
+		return job, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get Job %s : %s", auditJob.Name, err)
+	}
+
+	job, err = auditClient.BatchV1().Jobs(auditJob.Namespace).Create(ctx, &auditJob, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Job %s : %s", auditJob.Name, err)
+	}
+
+	return job, nil
+}
+
+// ResourceNameForBundle returns a name derived from the bundle name which is valid for k8s resources
+func ResourceNameForBundle(bundleName string) string {
+	name := shortenName(bundleResourceName(bundleName), maxResourceNameLength)
+
+	return strings.TrimRight(name, "-.")
+}
+
+// bundleResourceName returns the bundle name with the characters invalid for k8s resources replaced, before
+// it is shortened
+func bundleResourceName(bundleName string) string {
+	name := strings.ToLower(bundleName)
+	name = invalidResourceNameChars.ReplaceAllString(name, "-")
+
+	return resourceNamePrefix + name
+}
+
+// shortenName truncates the name to the length, replacing its end with a hash of the whole name so the names
+// sharing the same beginning stay apart
+func shortenName(name string, length int) string {
+	if len(name) <= length {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+
+	return strings.TrimRight(name[:length-nameHashLength-1], "-.") + "-" + hash
 }
 
 func (c ClusterClaimDeleteFlagSetNameFlagEmptyError) Error() string {
@@ -300,3 +1473,8 @@ func (c ClusterClaimNameLengthIncorrectError) Error() string {
 func (c ClusterClaimNameHasInvalidCharactersError) Error() string {
 	return "--name contains invalid characters; ASCII alphanumeric characters only permitted."
 }
+
+// SuffixedJobName returns the name followed by the suffix, shortened to be valid for k8s resources
+func SuffixedJobName(name, suffix string) string {
+	return shortenName(fmt.Sprintf("%s-%s", name, suffix), maxResourceNameLength)
+}
@@ -0,0 +1,427 @@
+package orchestrate
+
+import (
+	"audit-tool-orchestrator/pkg/orchestrate/fake"
+	"context"
+	"errors"
+	"fmt"
+	hivev1api "github.com/openshift/hive/apis/hive/v1"
+	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	k8stesting "k8s.io/client-go/testing"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testNamespace = "ato"
+const testJobName = "ato-etcd-v0-9-4"
+
+// waitForWatches blocks until count watches were opened on the resource, e.g. once a Job was created again
+func waitForWatches(ctx context.Context, client *k8stesting.Fake, resource string, count int) error {
+	return wait.PollImmediateUntil(10*time.Millisecond, func() (bool, error) {
+		opened := 0
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "watch" && action.GetResource().Resource == resource {
+				opened++
+			}
+		}
+
+		return opened >= count, nil
+	}, ctx.Done())
+}
+
+func newClusterPool(ready bool) *hivev1api.ClusterPool {
+	pool := &hivev1api.ClusterPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "ato-pool", Namespace: testNamespace},
+		Spec:       hivev1api.ClusterPoolSpec{Size: 2, RunningCount: 1},
+	}
+	if ready {
+		pool.Status = hivev1api.ClusterPoolStatus{Size: 2, Ready: 1, Standby: 1}
+	}
+
+	return pool
+}
+
+func newAuditJob() batchv1.Job {
+	return batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: testJobName, Namespace: testNamespace}}
+}
+
+func TestWaitForSuccessfulClusterPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    *hivev1api.ClusterPool
+		timeout time.Duration
+		update  func(ctx context.Context, hvclient hivev1client.Interface) error
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "ready already",
+			pool:    newClusterPool(true),
+			timeout: 5 * time.Second,
+			want:    "Pool Ready",
+		},
+		{
+			name:    "ready once the clusters are provisioned",
+			pool:    newClusterPool(false),
+			timeout: 5 * time.Second,
+			update: func(ctx context.Context, hvclient hivev1client.Interface) error {
+				return fake.SetClusterPoolReady(ctx, hvclient, testNamespace, "ato-pool")
+			},
+			want: "Pool Ready",
+		},
+		{
+			name:    "not ready before the deadline",
+			pool:    newClusterPool(false),
+			timeout: 200 * time.Millisecond,
+			want:    "Pool Not Ready",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			hvclient := fake.NewHiveClient(tt.pool)
+			updated := make(chan error, 1)
+			go func() {
+				if tt.update == nil {
+					updated <- nil
+					return
+				}
+				if err := fake.WaitForWatch(ctx, &hvclient.Fake, "clusterpools"); err != nil {
+					updated <- err
+					return
+				}
+				updated <- tt.update(ctx, hvclient)
+			}()
+
+			got, err := WaitForSuccessfulClusterPool(ctx, hvclient, tt.pool)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WaitForSuccessfulClusterPool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("WaitForSuccessfulClusterPool() = %s, want %s", got, tt.want)
+			}
+			if err := <-updated; err != nil {
+				t.Errorf("unable to update the ClusterPool: %v", err)
+			}
+		})
+	}
+}
+
+func TestClaimClusterForBundle(t *testing.T) {
+	flags := ClaimFlags{Name: "ato-claim-0", Namespace: testNamespace, PoolName: "ato-pool", BundleName: "etcd.v0.9.4"}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		update  func(ctx context.Context, hvclient hivev1client.Interface) error
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "cluster running",
+			timeout: 5 * time.Second,
+			update: func(ctx context.Context, hvclient hivev1client.Interface) error {
+				return fake.SetClusterClaimRunning(ctx, hvclient, testNamespace, flags.Name, "ato-pool-x7d2k")
+			},
+			want: "ato-pool-x7d2k",
+		},
+		{
+			name:    "pending until the deadline",
+			timeout: 200 * time.Millisecond,
+			update: func(ctx context.Context, hvclient hivev1client.Interface) error {
+				return fake.SetClusterClaimPending(ctx, hvclient, testNamespace, flags.Name, "NoClusters")
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			hvclient := fake.NewHiveClient()
+			updated := make(chan error, 1)
+			go func() {
+				if err := fake.WaitForWatch(ctx, &hvclient.Fake, "clusterclaims"); err != nil {
+					updated <- err
+					return
+				}
+				updated <- tt.update(ctx, hvclient)
+			}()
+
+			got, err := ClaimClusterForBundle(ctx, hvclient, flags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClaimClusterForBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ClaimClusterForBundle() = %s, want %s", got, tt.want)
+			}
+			if err := <-updated; err != nil {
+				t.Errorf("unable to update the ClusterClaim: %v", err)
+			}
+		})
+	}
+}
+
+func TestWaitForAuditJob(t *testing.T) {
+	pollInterval := jobPodsPollInterval
+	jobPodsPollInterval = 10 * time.Millisecond
+	defer func() { jobPodsPollInterval = pollInterval }()
+
+	tests := []struct {
+		name    string
+		update  func(ctx context.Context, k8sclient kubernetes.Interface) error
+		want    JobStatus
+		wantErr bool
+	}{
+		{
+			name: "complete",
+			update: func(ctx context.Context, k8sclient kubernetes.Interface) error {
+				return fake.CompleteJob(ctx, k8sclient, testNamespace, testJobName)
+			},
+			want: JobStatus{Result: batchv1.JobComplete},
+		},
+		{
+			name: "backoff limit exceeded",
+			update: func(ctx context.Context, k8sclient kubernetes.Interface) error {
+				return fake.FailJob(ctx, k8sclient, testNamespace, testJobName, "BackoffLimitExceeded",
+					"Job has reached the specified backoff limit")
+			},
+			want: JobStatus{Result: batchv1.JobFailed, Reason: "BackoffLimitExceeded"},
+		},
+		{
+			name: "image pull backoff",
+			update: func(ctx context.Context, k8sclient kubernetes.Interface) error {
+				if err := fake.AddWaitingPod(ctx, k8sclient, testNamespace, testJobName, "ImagePullBackOff"); err != nil {
+					return err
+				}
+				return fake.FailJob(ctx, k8sclient, testNamespace, testJobName, "BackoffLimitExceeded",
+					"Job has reached the specified backoff limit")
+			},
+			want: JobStatus{Result: batchv1.JobFailed, Reason: "ImagePullBackOff", Infrastructure: true},
+		},
+		{
+			name: "deadline exceeded before any pod started",
+			update: func(ctx context.Context, k8sclient kubernetes.Interface) error {
+				if err := fake.AddWaitingPod(ctx, k8sclient, testNamespace, testJobName, "ContainerCreating"); err != nil {
+					return err
+				}
+				// the pods are seen waiting before the Job controller deletes them at the deadline
+				time.Sleep(10 * jobPodsPollInterval)
+				if err := fake.DeleteJobPods(ctx, k8sclient, testNamespace, testJobName); err != nil {
+					return err
+				}
+				return fake.FailJob(ctx, k8sclient, testNamespace, testJobName, jobDeadlineExceeded,
+					"Job was active longer than specified deadline")
+			},
+			want: JobStatus{Result: batchv1.JobFailed, Reason: "ContainerCreating", Infrastructure: true},
+		},
+		{
+			name: "deadline exceeded without pods",
+			update: func(ctx context.Context, k8sclient kubernetes.Interface) error {
+				time.Sleep(10 * jobPodsPollInterval)
+				return fake.FailJob(ctx, k8sclient, testNamespace, testJobName, jobDeadlineExceeded,
+					"Job was active longer than specified deadline")
+			},
+			want: JobStatus{Result: batchv1.JobFailed, Reason: jobDeadlineExceeded, Infrastructure: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			auditJob := newAuditJob()
+			k8sclient := fake.NewK8sClient(&auditJob)
+			updated := make(chan error, 1)
+			go func() {
+				if err := fake.WaitForWatch(ctx, &k8sclient.Fake, "jobs"); err != nil {
+					updated <- err
+					return
+				}
+				updated <- tt.update(ctx, k8sclient)
+			}()
+
+			got, err := WaitForAuditJob(ctx, k8sclient, &auditJob)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WaitForAuditJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Result != tt.want.Result || got.Reason != tt.want.Reason ||
+				got.Infrastructure != tt.want.Infrastructure {
+				t.Errorf("WaitForAuditJob() = %+v, want %+v", got, tt.want)
+			}
+			if err := <-updated; err != nil {
+				t.Errorf("unable to update the Job: %v", err)
+			}
+		})
+	}
+}
+
+func TestAwaitAuditJob(t *testing.T) {
+	imagePullBackOff := func(ctx context.Context, k8sclient kubernetes.Interface) error {
+		if err := fake.AddWaitingPod(ctx, k8sclient, testNamespace, testJobName, "ImagePullBackOff"); err != nil {
+			return err
+		}
+		return fake.FailJob(ctx, k8sclient, testNamespace, testJobName, "BackoffLimitExceeded",
+			"Job has reached the specified backoff limit")
+	}
+	complete := func(ctx context.Context, k8sclient kubernetes.Interface) error {
+		return fake.CompleteJob(ctx, k8sclient, testNamespace, testJobName)
+	}
+
+	tests := []struct {
+		name    string
+		retries int
+		// updates are applied in turn to each attempt of the Job
+		updates []func(ctx context.Context, k8sclient kubernetes.Interface) error
+		want    JobStatus
+		wantErr bool
+	}{
+		{
+			name:    "complete",
+			retries: 1,
+			updates: []func(ctx context.Context, k8sclient kubernetes.Interface) error{complete},
+			want:    JobStatus{Result: batchv1.JobComplete},
+		},
+		{
+			name:    "backoff limit exceeded is not retried",
+			retries: 1,
+			updates: []func(ctx context.Context, k8sclient kubernetes.Interface) error{
+				func(ctx context.Context, k8sclient kubernetes.Interface) error {
+					return fake.FailJob(ctx, k8sclient, testNamespace, testJobName, "BackoffLimitExceeded",
+						"Job has reached the specified backoff limit")
+				},
+			},
+			want: JobStatus{Result: batchv1.JobFailed, Reason: "BackoffLimitExceeded"},
+		},
+		{
+			name:    "image pull backoff retried",
+			retries: 1,
+			updates: []func(ctx context.Context, k8sclient kubernetes.Interface) error{
+				imagePullBackOff,
+				complete,
+			},
+			want: JobStatus{Result: batchv1.JobComplete},
+		},
+		{
+			name:    "image pull backoff without retries",
+			retries: 0,
+			updates: []func(ctx context.Context, k8sclient kubernetes.Interface) error{imagePullBackOff},
+			want:    JobStatus{Result: batchv1.JobFailed, Reason: "ImagePullBackOff", Infrastructure: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			auditJob := newAuditJob()
+			k8sclient := fake.NewK8sClient()
+			job, err := k8sclient.BatchV1().Jobs(testNamespace).Create(ctx, &auditJob, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated := make(chan error, 1)
+			go func() {
+				for i, update := range tt.updates {
+					if err := waitForWatches(ctx, &k8sclient.Fake, "jobs", i+1); err != nil {
+						updated <- fmt.Errorf("no watch was opened on attempt %d : %s", i+1, err)
+						return
+					}
+					if err := update(ctx, k8sclient); err != nil {
+						updated <- err
+						return
+					}
+				}
+				updated <- nil
+			}()
+
+			got, err := AwaitAuditJob(ctx, k8sclient, auditJob, job, tt.retries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AwaitAuditJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Result != tt.want.Result || got.Reason != tt.want.Reason ||
+				got.Infrastructure != tt.want.Infrastructure {
+				t.Errorf("AwaitAuditJob() = %+v, want %+v", got, tt.want)
+			}
+			if err := <-updated; err != nil {
+				t.Errorf("unable to update the Job: %v", err)
+			}
+		})
+	}
+}
+
+func TestPoolJobName(t *testing.T) {
+	// the bundles only differ past the 63 characters of a k8s resource name
+	longBundle := "operator-with-a-very-long-name-for-the-tests.v1.2.3-rc.0+build.20220101"
+	otherLongBundle := "operator-with-a-very-long-name-for-the-tests.v1.2.3-rc.0+build.20220102"
+
+	tests := []struct {
+		name       string
+		bundleName string
+		poolName   string
+		want       string
+		other      string
+	}{
+		{
+			name:       "short bundle",
+			bundleName: "etcd.v0.9.4",
+			want:       "ato-etcd.v0.9.4",
+		},
+		{
+			name:       "short bundle on a pool",
+			bundleName: "etcd.v0.9.4",
+			poolName:   "ocp-4-10-aws",
+			want:       "ato-etcd.v0.9.4-ocp-4-10-aws",
+		},
+		{
+			name:       "long bundles",
+			bundleName: longBundle,
+			other:      otherLongBundle,
+		},
+		{
+			name:       "long bundles on a pool",
+			bundleName: longBundle,
+			poolName:   "ocp-4-10-aws",
+			other:      otherLongBundle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PoolJobName(tt.bundleName, tt.poolName)
+			if len(tt.want) > 0 && got != tt.want {
+				t.Errorf("PoolJobName() = %s, want %s", got, tt.want)
+			}
+			if len(got) > maxResourceNameLength {
+				t.Errorf("PoolJobName() = %s, longer than %d characters", got, maxResourceNameLength)
+			}
+			if !strings.HasSuffix(got, tt.poolName) {
+				t.Errorf("PoolJobName() = %s, want the pool %s kept", got, tt.poolName)
+			}
+			if len(tt.other) == 0 {
+				return
+			}
+			if other := PoolJobName(tt.other, tt.poolName); other == got {
+				t.Errorf("PoolJobName() = %s for both %s and %s", got, tt.bundleName, tt.other)
+			}
+		})
+	}
+
+	if got := SuffixedJobName(longBundle, "scorecard"); got == SuffixedJobName(otherLongBundle, "scorecard") {
+		t.Errorf("SuffixedJobName() = %s for both %s and %s", got, longBundle, otherLongBundle)
+	}
+}
@@ -1,6 +1,17 @@
 package orchestrate
 
-import "github.com/openshift/hive/apis/hive/v1/azure"
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/state"
+	"context"
+	"github.com/openshift/hive/apis/hive/v1/azure"
+	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/client-go/kubernetes"
+	"sync"
+	"time"
+)
 
 type PoolFlags struct {
 	Name                             string                 `json:"name"`
@@ -18,6 +29,10 @@ type PoolFlags struct {
 	AzureCloudName                   azure.CloudEnvironment `json:"azurecloudname"`
 	IBMAccountID                     string                 `json:"ibmaccountid"`
 	IBMCISInstanceCRN                string                 `json:"ibmcisinstancecrn"`
+	// CreateImageSet creates the ClusterImageSet of the OpenShift version when the Hive cluster has none
+	CreateImageSet bool `json:"createImageSet"`
+	// ReleaseImage is the release image of the created ClusterImageSet. It is read from the mirror when empty.
+	ReleaseImage string `json:"releaseImage"`
 }
 
 type ClaimFlags struct {
@@ -26,6 +41,10 @@ type ClaimFlags struct {
 	PoolName   string `json:"poolName"`
 	BundleName string `json:"bundleName"`
 	Delete     bool   `json:"delete"`
+	// JobNamespace is the namespace of the claimed cluster where the secrets of the audit Jobs are added
+	JobNamespace string `json:"jobNamespace"`
+	// BucketCredentials are provisioned on the claimed cluster for the audit Job
+	BucketCredentials bucket.CredentialsFlags `json:"bucketCredentials"`
 }
 
 type ClusterClaimDeleteFlagSetNameFlagEmptyError struct{}
@@ -33,10 +52,121 @@ type ClusterClaimNameLengthIncorrectError struct{}
 type ClusterClaimNameHasInvalidCharactersError struct{}
 
 type JobFlags struct {
-	Name        string `json:"name"`
-	BundleImage string `json:"bundleImage"`
-	BundleName  string `json:"bundleName"`
-	BucketName  string `json:"bucket-name"`
-	ClaimName   string `json:"claim-name"`
-	Kubeconfig  string `json:"kubeconfig"`
+	Name        string   `json:"name"`
+	BundleImage string   `json:"bundleImage"`
+	BundleName  string   `json:"bundleName"`
+	BucketName  string   `json:"bucket-name"`
+	ClaimName   string   `json:"claim-name"`
+	Kubeconfig  string   `json:"kubeconfig"`
+	Template    string   `json:"template"`
+	Suites      []string `json:"suites"`
+	Parallel    bool     `json:"parallel"`
+	// Deadline sets the active deadline of the Job. The one of the Job template is kept when zero.
+	Deadline time.Duration `json:"deadline"`
+	// Retries is the number of times the Job is run again when it fails for infrastructure reasons
+	Retries int `json:"retries"`
+	// FollowLogs streams the container logs of the Job to stdout while it runs
+	FollowLogs bool `json:"followLogs"`
+	// ArtifactsDir receives the logs, Job/Pod YAML and events of each Job. Nothing is saved when empty.
+	ArtifactsDir string `json:"artifactsDir"`
+	// UploadArtifacts also stores the artifacts in the bucket
+	UploadArtifacts bool `json:"uploadArtifacts"`
+	Secure          bool `json:"secure"`
+	// RunID scopes the artifacts uploaded to the bucket to the run of the Job, if any
+	RunID string `json:"runId,omitempty"`
+	// BucketCredentials are provisioned on the cluster under test before the Job is created
+	BucketCredentials bucket.CredentialsFlags `json:"bucketCredentials"`
+	// Credentials are the bucket credentials loaded from BucketCredentials, used to upload the artifacts
+	Credentials bucket.Credentials `json:"-"`
+	// JUnit is the path of the JUnit XML report written once the Job, or the suites, finished
+	JUnit string `json:"junit"`
+}
+
+// JobStatus is the final status of an audit Job
+type JobStatus struct {
+	Result  batchv1.JobConditionType `json:"result"`
+	Reason  string                   `json:"reason,omitempty"`
+	Message string                   `json:"message,omitempty"`
+	// Infrastructure is set when the Job failed for reasons unrelated to the audit, e.g. image pull errors
+	// or evictions
+	Infrastructure bool `json:"infrastructure,omitempty"`
+}
+
+// jobPods is what was seen of the pods of a running Job, since the pods which never started are deleted when
+// the Job exceeds its deadline
+type jobPods struct {
+	mu       sync.Mutex
+	observed bool
+	started  bool
+	waiting  *JobStatus
+	stop     context.CancelFunc
+}
+
+// JobTemplateData holds the variables available to the Job templates
+type JobTemplateData struct {
+	Name        string
+	BundleImage string
+	BundleName  string
+	BucketName  string
+	// BucketSecret is the Secret holding the MINIO_* credentials of the bucket on the cluster under test
+	BucketSecret string
+	ClaimName    string
+}
+
+type RunFlags struct {
+	IndexImage      string             `json:"image"`
+	BundleList      string             `json:"bundleList"`
+	ContainerEngine string             `json:"containerEngine"`
+	Mode            string             `json:"mode"`
+	Filter          index.BundleFilter `json:"filter"`
+	BucketName      string             `json:"bucket-name"`
+	JobTemplate     string             `json:"jobTemplate"`
+	JobDeadline     time.Duration      `json:"jobDeadline"`
+	JobRetries      int                `json:"jobRetries"`
+	ArtifactsDir    string             `json:"artifactsDir"`
+	UploadArtifacts bool               `json:"uploadArtifacts"`
+	Secure          bool               `json:"secure"`
+	// BucketCredentials are provisioned on each claimed cluster
+	BucketCredentials bucket.CredentialsFlags `json:"bucketCredentials"`
+	// JUnit is the path of the JUnit XML report written when the run ends
+	JUnit   string    `json:"junit"`
+	StateDB string    `json:"stateDB"`
+	Workers int       `json:"workers"`
+	Pool    PoolFlags `json:"pool"`
+	// PoolMatrix is the file of the pools the bundles are routed to by their supported OpenShift versions
+	PoolMatrix string `json:"poolMatrix"`
+	// Platforms select the pools of the matrix on which the bundles are audited. Every platform when empty.
+	Platforms []string `json:"platforms"`
+	// Pools are the pools of the matrix the run audits on, recorded so it is resumed with the same pools
+	Pools []PoolFlags `json:"pools,omitempty"`
+}
+
+// BundleAuditResult is the outcome of auditing one bundle on a claimed cluster
+type BundleAuditResult struct {
+	RunID       string                   `json:"runId,omitempty"`
+	BundleName  string                   `json:"bundleName"`
+	PackageName string                   `json:"packageName"`
+	PoolName    string                   `json:"poolName"`
+	ClaimName   string                   `json:"claimName"`
+	Result      batchv1.JobConditionType `json:"result"`
+	Reason      string                   `json:"reason,omitempty"`
+	Message     string                   `json:"message,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+// worker audits bundles one after the other on the cluster of its ClusterClaim
+type worker struct {
+	claimFlags      ClaimFlags
+	hvclient        hivev1client.Interface
+	k8sclient       kubernetes.Interface
+	store           *state.Store
+	flags           RunFlags
+	claimed         bool
+	cdNameNamespace string
+	clusterVersion  string
+	auditClient     kubernetes.Interface
+	kubeconfig      []byte
+	credentials     bucket.Credentials
+	// prepared holds the namespaces of the claimed cluster which have the secrets of the audit Jobs
+	prepared map[string]bool
 }
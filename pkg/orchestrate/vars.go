@@ -0,0 +1,83 @@
+package orchestrate
+
+import (
+	_ "embed"
+	batchv1 "k8s.io/api/batch/v1"
+	"regexp"
+	"time"
+)
+
+const resourceNamePrefix = "ato-"
+
+// clusterVersionLabel is set by Hive on the ClusterDeployments with the installed OpenShift version
+const clusterVersionLabel = "hive.openshift.io/version-major-minor-patch"
+const maxResourceNameLength = 63
+
+// nameHashLength is the length of the hash ending the names shortened to maxResourceNameLength
+const nameHashLength = 8
+
+var invalidResourceNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// AuditError is reported when the bundle could not be audited because the Job never ran
+const AuditError batchv1.JobConditionType = "Error"
+
+// DefaultJobNamespace is the namespace of the audit Jobs whose template sets none
+const DefaultJobNamespace = "default"
+
+// Platforms are the cloud platforms the ClusterPools can be created on
+var Platforms = []string{"aws", "azure", "gcp", "ibm"}
+
+// DefaultPlatform is used for the pools whose platform is not set, as SetPlatform does
+const DefaultPlatform = "aws"
+
+// bucketTemplateFields are the variables of the Job templates which use the bucket
+var bucketTemplateFields = regexp.MustCompile(`\.Bucket(Name|Secret)\b`)
+
+// ResultObjectName is the object, stored under the <run-id>/<bundle-name> prefix, holding the outcome of the audit
+const ResultObjectName = "result.json"
+
+// jobDeadlineGrace is waited for a Job past its active deadline before giving up on it
+const jobDeadlineGrace = 5 * time.Minute
+
+const DefaultJobRetries = 2
+
+// jobPodsPollInterval is how often the pods of a running audit Job are checked for reasons they can not start
+var jobPodsPollInterval = 15 * time.Second
+
+// jobDeadlineExceeded is the reason of the Failed condition of the Jobs which ran past their active deadline
+const jobDeadlineExceeded = "DeadlineExceeded"
+
+// NewAuditClient creates the client of each claimed cluster during a run. It can be replaced, e.g. to audit with
+// fake clients.
+var NewAuditClient = K8sClientForAudit
+
+// infrastructurePodReasons are the reasons of pods which could not run the audit because of the cluster
+var infrastructurePodReasons = map[string]bool{
+	"Evicted":                  true,
+	"NodeLost":                 true,
+	"NodeAffinity":             true,
+	"OutOfcpu":                 true,
+	"OutOfmemory":              true,
+	"Preempting":               true,
+	"Shutdown":                 true,
+	"UnexpectedAdmissionError": true,
+}
+
+// infrastructureContainerReasons are the reasons of containers which never started the audit
+var infrastructureContainerReasons = map[string]bool{
+	"ContainerCreating":          true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"ErrImageNeverPull":          true,
+	"ErrImagePull":               true,
+	"ImageInspectError":          true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+}
+
+const jobKind = "Job"
+
+// DefaultJobTemplate is the built-in Job template which audits the bundle with the capabilities-tool
+//
+//go:embed templates/audit-job.yaml
+var DefaultJobTemplate string
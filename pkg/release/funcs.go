@@ -0,0 +1,325 @@
+package release
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	hivev1api "github.com/openshift/hive/apis/hive/v1"
+	hivev1client "github.com/openshift/hive/pkg/client/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/mod/semver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"strings"
+)
+
+// IsValidOpenShiftVersion reports whether the OpenShift version of a pool is latest, a minor version such as 4.10
+// or 4.10.x, or an exact release such as 4.10.3. Empty is the latest release.
+func IsValidOpenShiftVersion(version string) bool {
+	selector := normalizeOpenShiftVersion(version)
+	if len(selector) == 0 || selector == LatestVersion {
+		return true
+	}
+
+	return semver.IsValid("v"+selector) && (isMinorVersion(selector) || isExactVersion(selector))
+}
+
+// IsOpenShiftRelease reports whether the version is a release such as 4.10.3, rather than latest or a minor version
+func IsOpenShiftRelease(version string) bool {
+	selector := normalizeOpenShiftVersion(version)
+	return semver.IsValid("v"+selector) && isExactVersion(selector)
+}
+
+// MatchesOpenShiftVersion reports whether the release is selected by the OpenShift version of a pool: every
+// release for latest, the 4.10 releases for 4.10 and 4.10.x, or the release itself for 4.10.3. Pre-releases
+// are only selected by their exact version.
+func MatchesOpenShiftVersion(version, release string) bool {
+	if !IsValidOpenShiftVersion(version) {
+		return false
+	}
+
+	releaseVersion := "v" + strings.TrimPrefix(release, "v")
+	if !semver.IsValid(releaseVersion) {
+		return false
+	}
+
+	selector := normalizeOpenShiftVersion(version)
+	switch {
+	case len(selector) == 0 || selector == LatestVersion:
+		return len(semver.Prerelease(releaseVersion)) == 0
+	case isMinorVersion(selector):
+		return semver.MajorMinor(releaseVersion) == "v"+selector && len(semver.Prerelease(releaseVersion)) == 0
+	}
+
+	return semver.Compare(releaseVersion, "v"+selector) == 0
+}
+
+// normalizeOpenShiftVersion returns the version without v prefix nor .x suffix
+func normalizeOpenShiftVersion(version string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".x")
+}
+
+// isMinorVersion reports whether the normalized version is a major.minor version
+func isMinorVersion(version string) bool {
+	return strings.Count(version, ".") == 1 && !strings.Contains(version, "-")
+}
+
+// isExactVersion reports whether the normalized version is a major.minor.patch release, maybe a pre-release
+func isExactVersion(version string) bool {
+	return strings.Count(strings.SplitN(version, "-", 2)[0], ".") == 2
+}
+
+// ClusterImageSetVersion returns the OpenShift version of the ClusterImageSet, read from the tag of its release
+// image or else from its name
+func ClusterImageSetVersion(imageSet hivev1api.ClusterImageSet) (string, bool) {
+	image := imageSet.Spec.ReleaseImage
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+
+	// images pinned by digest have no version
+	if i := strings.Index(image, ":"); i >= 0 && !strings.Contains(image, "@") {
+		if version := releaseVersionPattern.FindString(image[i+1:]); len(version) > 0 {
+			return version, true
+		}
+	}
+
+	version := releaseVersionPattern.FindString(imageSet.Name)
+
+	return version, len(version) > 0
+}
+
+// ResolveClusterImageSet returns the name of the ClusterImageSet of the Hive cluster with the highest release
+// selected by the OpenShift version. A ClusterImageSetNotFoundError is returned when there is none.
+func ResolveClusterImageSet(ctx context.Context, hvclient hivev1client.Interface, version string) (string, error) {
+	if !IsValidOpenShiftVersion(version) {
+		return "", fmt.Errorf("invalid OpenShift version %s", version)
+	}
+
+	imageSets, err := hvclient.HiveV1().ClusterImageSets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to list ClusterImageSets : %s", err)
+	}
+
+	var name, highest string
+	for _, imageSet := range imageSets.Items {
+		release, ok := ClusterImageSetVersion(imageSet)
+		if !ok || !MatchesOpenShiftVersion(version, release) {
+			continue
+		}
+
+		if len(highest) == 0 || semver.Compare("v"+release, "v"+highest) > 0 {
+			name = imageSet.Name
+			highest = release
+		}
+	}
+
+	if len(name) == 0 {
+		return "", ClusterImageSetNotFoundError{Version: version}
+	}
+
+	log.Infof("Using ClusterImageSet %s of OpenShift %s.\n", name, highest)
+
+	return name, nil
+}
+
+// GetOpenShiftRelease reads the release selected by the OpenShift version from its release.txt on the mirror:
+// the one of the stable channel for latest, of the stable-4.10 channel for 4.10, or of the exact release
+func GetOpenShiftRelease(ctx context.Context, mirrorURL, version string) (Release, error) {
+	release := Release{}
+
+	selector := normalizeOpenShiftVersion(version)
+	channel := "stable"
+	switch {
+	case !IsValidOpenShiftVersion(version):
+		return release, fmt.Errorf("invalid OpenShift version %s", version)
+	case len(selector) == 0 || selector == LatestVersion:
+	case isMinorVersion(selector):
+		channel = "stable-" + selector
+	default:
+		channel = selector
+	}
+
+	url := fmt.Sprintf("%s/%s/%s", strings.TrimRight(mirrorURL, "/"), channel, releaseFileName)
+
+	ctx, cancel := context.WithTimeout(ctx, mirrorTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return release, fmt.Errorf("unable to get the OpenShift release from %s : %s", url, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return release, fmt.Errorf("unable to get the OpenShift release from %s : %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return release, fmt.Errorf("unable to get the OpenShift release from %s : %s", url, resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if match := releaseNamePattern.FindStringSubmatch(scanner.Text()); match != nil && len(release.Version) == 0 {
+			release.Version = match[1]
+		}
+		if match := releaseImagePattern.FindStringSubmatch(scanner.Text()); match != nil && len(release.Image) == 0 {
+			release.Image = match[1]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return release, fmt.Errorf("unable to read the OpenShift release from %s : %s", url, err)
+	}
+
+	if len(release.Version) == 0 || len(release.Image) == 0 {
+		return release, fmt.Errorf("no OpenShift release found in %s", url)
+	}
+
+	return release, nil
+}
+
+// NewClusterImageSet builds the ClusterImageSet of the release
+func NewClusterImageSet(release Release) hivev1api.ClusterImageSet {
+	return hivev1api.ClusterImageSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: imageSetPrefix + release.Version,
+		},
+		Spec: hivev1api.ClusterImageSetSpec{
+			ReleaseImage: release.Image,
+		},
+	}
+}
+
+// EnsureClusterImageSet returns the name of the ClusterImageSet of the OpenShift version. When the Hive cluster
+// has none and create is set, it is created from the release image or else from the release read from the mirror.
+func EnsureClusterImageSet(ctx context.Context, hvclient hivev1client.Interface, version, releaseImage string,
+	create bool) (string, error) {
+	name, err := ResolveClusterImageSet(ctx, hvclient, version)
+	var notFound ClusterImageSetNotFoundError
+	if err == nil || !errors.As(err, &notFound) || !create {
+		return name, err
+	}
+
+	release := Release{Version: normalizeOpenShiftVersion(version), Image: releaseImage}
+	if len(release.Image) > 0 {
+		if !IsOpenShiftRelease(release.Version) {
+			return "", fmt.Errorf("the release image %s requires an exact OpenShift version, e.g. 4.10.3, "+
+				"instead of %s", releaseImage, version)
+		}
+	} else {
+		release, err = GetOpenShiftRelease(ctx, MirrorURL, version)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	imageSet := NewClusterImageSet(release)
+	_, err = hvclient.HiveV1().ClusterImageSets().Create(ctx, &imageSet, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("unable to create ClusterImageSet %s : %s", imageSet.Name, err)
+	}
+
+	log.Infof("ClusterImageSet %s created with release image %s.\n", imageSet.Name, release.Image)
+
+	return imageSet.Name, nil
+}
+
+// ParseOpenShiftVersions reads the range of the com.redhat.openshift.versions annotation: v4.8 for 4.8 and later,
+// v4.8-v4.10 for 4.8 to 4.10, =v4.8 for 4.8 only and the legacy v4.7,v4.8 list for 4.7 and later.
+// An empty value is every version.
+func ParseOpenShiftVersions(value string) (VersionRange, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return VersionRange{}, nil
+	}
+
+	if strings.HasPrefix(value, "=") {
+		version, err := MinorVersion(value[1:])
+		if err != nil {
+			return VersionRange{}, err
+		}
+
+		return VersionRange{Min: version, Max: version}, nil
+	}
+
+	if strings.Contains(value, ",") {
+		versionRange := VersionRange{}
+		for _, item := range strings.Split(value, ",") {
+			version, err := MinorVersion(item)
+			if err != nil {
+				return VersionRange{}, err
+			}
+
+			if len(versionRange.Min) == 0 || semver.Compare(version, versionRange.Min) < 0 {
+				versionRange.Min = version
+			}
+		}
+
+		return versionRange, nil
+	}
+
+	if i := strings.Index(value, "-"); i >= 0 {
+		min, err := MinorVersion(value[:i])
+		if err != nil {
+			return VersionRange{}, err
+		}
+
+		max, err := MinorVersion(value[i+1:])
+		if err != nil {
+			return VersionRange{}, err
+		}
+
+		if semver.Compare(min, max) > 0 {
+			return VersionRange{}, fmt.Errorf("invalid OpenShift versions %s : %s is greater than %s", value, min, max)
+		}
+
+		return VersionRange{Min: min, Max: max}, nil
+	}
+
+	min, err := MinorVersion(value)
+	if err != nil {
+		return VersionRange{}, err
+	}
+
+	return VersionRange{Min: min}, nil
+}
+
+// Contains reports whether the major.minor of the OpenShift version is within the range
+func (r VersionRange) Contains(version string) bool {
+	canonical, err := MinorVersion(version)
+	if err != nil {
+		return false
+	}
+
+	if len(r.Min) > 0 && semver.Compare(canonical, r.Min) < 0 {
+		return false
+	}
+
+	return len(r.Max) == 0 || semver.Compare(canonical, r.Max) <= 0
+}
+
+// MinorVersion returns the major.minor of the version, e.g. v4.10 for 4.10.3 or v4.10
+func MinorVersion(version string) (string, error) {
+	canonical := "v" + normalizeOpenShiftVersion(version)
+
+	if !semver.IsValid(canonical) {
+		return "", fmt.Errorf("invalid OpenShift version %s", version)
+	}
+
+	return semver.MajorMinor(canonical), nil
+}
+
+func (c ClusterImageSetNotFoundError) Error() string {
+	version := c.Version
+	if len(version) == 0 {
+		version = LatestVersion
+	}
+
+	return fmt.Sprintf("no ClusterImageSet of OpenShift %s found on the Hive cluster. Create it, or set "+
+		"--create-image-set to create it from the release image.", version)
+}
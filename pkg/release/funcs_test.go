@@ -0,0 +1,273 @@
+package release_test
+
+import (
+	"audit-tool-orchestrator/pkg/orchestrate/fake"
+	"audit-tool-orchestrator/pkg/release"
+	"context"
+	"errors"
+	hivev1api "github.com/openshift/hive/apis/hive/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const releaseTxt = `Client tools for OpenShift
+--------------------------
+
+Name:      4.10.18
+Digest:    sha256:195de2a5ef3af1083620a62a45ea61ac1233ffa27bbce7b30609a69775aeca19
+Created:   2022-06-07T09:05:46Z
+OS/Arch:   linux/amd64
+Manifests: 544
+
+Pull From: quay.io/openshift-release-dev/ocp-release@sha256:195de2a5ef3af1083620a62a45ea61ac1233ffa27bbce7b30609a69775aeca19
+`
+
+func newImageSet(name, releaseImage string) *hivev1api.ClusterImageSet {
+	return &hivev1api.ClusterImageSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       hivev1api.ClusterImageSetSpec{ReleaseImage: releaseImage},
+	}
+}
+
+// newMirror serves the release.txt of each channel path, and an error for the others
+func newMirror(t *testing.T, channels map[string]string) *httptest.Server {
+	t.Helper()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := channels[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(mirror.Close)
+
+	return mirror
+}
+
+func TestResolveClusterImageSet(t *testing.T) {
+	imageSets := []*hivev1api.ClusterImageSet{
+		newImageSet("img4.9.40-x86-64-appsub", "quay.io/openshift-release-dev/ocp-release:4.9.40-x86_64"),
+		newImageSet("img4.10.3-x86-64-appsub", "quay.io/openshift-release-dev/ocp-release:4.10.3-x86_64"),
+		newImageSet("img4.10.18-x86-64-appsub", "quay.io/openshift-release-dev/ocp-release:4.10.18-x86_64"),
+		newImageSet("img4.10.9-x86-64-appsub", "quay.io/openshift-release-dev/ocp-release:4.10.9-x86_64"),
+		newImageSet("ocp-4.11.0-rc.1", "quay.io/openshift-release-dev/ocp-release:4.11.0-rc.1-x86_64"),
+		// pinned by digest, the version is read from the name
+		newImageSet("ocp-4.8.45", "quay.io/openshift-release-dev/ocp-release@sha256:4b5c2d8e1f"),
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "highest release of the minor version",
+			version: "4.10",
+			want:    "img4.10.18-x86-64-appsub",
+		},
+		{
+			name:    "minor version with .x suffix",
+			version: "v4.10.x",
+			want:    "img4.10.18-x86-64-appsub",
+		},
+		{
+			name:    "latest skips pre-releases",
+			version: release.LatestVersion,
+			want:    "img4.10.18-x86-64-appsub",
+		},
+		{
+			name:    "exact release",
+			version: "4.10.3",
+			want:    "img4.10.3-x86-64-appsub",
+		},
+		{
+			name:    "exact pre-release",
+			version: "4.11.0-rc.1",
+			want:    "ocp-4.11.0-rc.1",
+		},
+		{
+			name:    "release pinned by digest",
+			version: "4.8",
+			want:    "ocp-4.8.45",
+		},
+		{
+			name:    "no ClusterImageSet of the version",
+			version: "4.11",
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			version: "4.10.3.1",
+			wantErr: true,
+		},
+	}
+
+	var objects []runtime.Object
+	for _, imageSet := range imageSets {
+		objects = append(objects, imageSet)
+	}
+	hvclient := fake.NewHiveClient(objects...)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := release.ResolveClusterImageSet(context.Background(), hvclient, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveClusterImageSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveClusterImageSet() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOpenShiftRelease(t *testing.T) {
+	mirror := newMirror(t, map[string]string{
+		"/stable/release.txt":      releaseTxt,
+		"/stable-4.10/release.txt": releaseTxt,
+		"/4.10.18/release.txt":     releaseTxt,
+		"/stable-4.9/release.txt":  "",
+		"/stable-4.8/release.txt":  "Name:      4.8.45\n",
+	})
+	want := release.Release{
+		Version: "4.10.18",
+		Image: "quay.io/openshift-release-dev/ocp-release@sha256:" +
+			"195de2a5ef3af1083620a62a45ea61ac1233ffa27bbce7b30609a69775aeca19",
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		want    release.Release
+		wantErr bool
+	}{
+		{
+			name:    "latest from the stable channel",
+			version: release.LatestVersion,
+			want:    want,
+		},
+		{
+			name:    "minor version from its stable channel",
+			version: "4.10",
+			want:    want,
+		},
+		{
+			name:    "exact release",
+			version: "4.10.18",
+			want:    want,
+		},
+		{
+			name:    "channel not found",
+			version: "4.11",
+			wantErr: true,
+		},
+		{
+			name:    "empty release.txt",
+			version: "4.9",
+			wantErr: true,
+		},
+		{
+			name:    "release.txt without release image",
+			version: "4.8",
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			version: "four",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := release.GetOpenShiftRelease(context.Background(), mirror.URL, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOpenShiftRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("GetOpenShiftRelease() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureClusterImageSet(t *testing.T) {
+	mirror := newMirror(t, map[string]string{"/stable-4.10/release.txt": releaseTxt})
+	mirrorURL := release.MirrorURL
+	release.MirrorURL = mirror.URL
+	defer func() { release.MirrorURL = mirrorURL }()
+
+	tests := []struct {
+		name         string
+		version      string
+		releaseImage string
+		create       bool
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:    "existing ClusterImageSet",
+			version: "4.9",
+			want:    "img4.9.40-x86-64-appsub",
+		},
+		{
+			name:    "missing ClusterImageSet not created",
+			version: "4.10",
+			wantErr: true,
+		},
+		{
+			name:    "created from the mirror",
+			version: "4.10",
+			create:  true,
+			want:    "ocp-4.10.18",
+		},
+		{
+			name:         "created from the release image",
+			version:      "4.10.3",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.10.3-x86_64",
+			create:       true,
+			want:         "ocp-4.10.3",
+		},
+		{
+			name:         "release image of a minor version",
+			version:      "4.10",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.10.3-x86_64",
+			create:       true,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hvclient := fake.NewHiveClient(
+				newImageSet("img4.9.40-x86-64-appsub", "quay.io/openshift-release-dev/ocp-release:4.9.40-x86_64"))
+
+			got, err := release.EnsureClusterImageSet(context.Background(), hvclient, tt.version, tt.releaseImage,
+				tt.create)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EnsureClusterImageSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("EnsureClusterImageSet() = %s, want %s", got, tt.want)
+			}
+
+			var notFound release.ClusterImageSetNotFoundError
+			if tt.wantErr && !tt.create && !errors.As(err, &notFound) {
+				t.Errorf("EnsureClusterImageSet() error = %v, want a ClusterImageSetNotFoundError", err)
+			}
+			if len(tt.want) == 0 {
+				return
+			}
+			if _, err := hvclient.HiveV1().ClusterImageSets().Get(context.Background(), tt.want,
+				metav1.GetOptions{}); err != nil {
+				t.Errorf("EnsureClusterImageSet() did not keep ClusterImageSet %s: %v", tt.want, err)
+			}
+		})
+	}
+}
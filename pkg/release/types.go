@@ -0,0 +1,20 @@
+package release
+
+// Release is an OpenShift release published on the mirror
+type Release struct {
+	Version string
+	// Image is the pull spec of the release image
+	Image string
+}
+
+// ClusterImageSetNotFoundError is returned when no ClusterImageSet of the Hive cluster has the OpenShift version
+type ClusterImageSetNotFoundError struct {
+	Version string
+}
+
+// VersionRange is a range of OpenShift versions such as the one of the com.redhat.openshift.versions annotation
+type VersionRange struct {
+	// Min and Max are the major.minor bounds as canonical semantic versions, e.g. v4.10. Unbounded when empty.
+	Min string
+	Max string
+}
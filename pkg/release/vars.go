@@ -0,0 +1,27 @@
+package release
+
+import (
+	"regexp"
+	"time"
+)
+
+// LatestVersion as the OpenShift version of a pool selects the highest release
+const LatestVersion = "latest"
+
+// MirrorURL publishes the release.txt of each OpenShift release and stable channel. It is read to create the
+// missing ClusterImageSets and can be replaced, e.g. by a local stand-in.
+var MirrorURL = "https://mirror.openshift.com/pub/openshift-v4/clients/ocp"
+
+const mirrorTimeout = 30 * time.Second
+const releaseFileName = "release.txt"
+
+// imageSetPrefix is the prefix of the names of the ClusterImageSets created by the orchestrator
+const imageSetPrefix = "ocp-"
+
+// releaseVersionPattern finds the OpenShift version in the names and release images of the ClusterImageSets,
+// e.g. 4.10.3 in img4.10.3-x86-64-appsub or quay.io/openshift-release-dev/ocp-release:4.10.3-x86_64
+var releaseVersionPattern = regexp.MustCompile(`\d+\.\d+\.\d+(-(rc|fc|ec)\.\d+)?`)
+
+// the lines of release.txt with the version and the release image
+var releaseNamePattern = regexp.MustCompile(`^Name:\s*(\S+)`)
+var releaseImagePattern = regexp.MustCompile(`^Pull From:\s*(\S+)`)
@@ -0,0 +1,635 @@
+package report
+
+import (
+	. "audit-tool-orchestrator/pkg"
+	"audit-tool-orchestrator/pkg/artifacts"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/state"
+	"audit-tool-orchestrator/pkg/suite"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"golang.org/x/mod/semver"
+	htmltemplate "html/template"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// NewReport builds the report of the run from the progress recorded in the state store
+func NewReport(store *state.Store, runID string) (Report, error) {
+	report := Report{RunID: runID, GenerateAt: time.Now().Format(generateAtLayout)}
+
+	run, err := store.GetRun(runID)
+	if err != nil {
+		return report, err
+	}
+	report.IndexImage = run.IndexImage
+
+	runFlags := orchestrate.RunFlags{}
+	if err := json.Unmarshal([]byte(run.Flags), &runFlags); err != nil {
+		return report, fmt.Errorf("unable to read the flags of run %s : %s", runID, err)
+	}
+
+	bundles, err := store.Bundles(runID)
+	if err != nil {
+		return report, err
+	}
+
+	packages := map[string]*PackageReport{}
+	for _, bundle := range bundles {
+		bundleReport := NewBundleReport(bundle, runFlags)
+
+		packageReport, ok := packages[bundleReport.PackageName]
+		if !ok {
+			packageReport = &PackageReport{PackageName: bundleReport.PackageName, DefaultChannel: bundleReport.DefaultChannel}
+			packages[bundleReport.PackageName] = packageReport
+		}
+		packageReport.Bundles = append(packageReport.Bundles, bundleReport)
+
+		report.Summary.Bundles++
+		switch bundleReport.Result {
+		case batchv1.JobComplete:
+			report.Summary.Succeeded++
+		case "":
+			report.Summary.Pending++
+		default:
+			report.Summary.Failed++
+		}
+	}
+
+	for _, packageReport := range packages {
+		sort.Slice(packageReport.Bundles, func(i, j int) bool {
+			bundles := packageReport.Bundles
+			if bundles[i].BundleName != bundles[j].BundleName {
+				return bundles[i].BundleName < bundles[j].BundleName
+			}
+			return bundles[i].PoolName < bundles[j].PoolName
+		})
+		report.Packages = append(report.Packages, *packageReport)
+	}
+
+	sort.Slice(report.Packages, func(i, j int) bool {
+		return report.Packages[i].PackageName < report.Packages[j].PackageName
+	})
+
+	return report, nil
+}
+
+// NewBundleReport returns the report of the bundle, locating its artifacts with the flags of the run
+func NewBundleReport(bundle state.BundleState, runFlags orchestrate.RunFlags) BundleReport {
+	bundleReport := BundleReport{
+		BundleName:     bundle.BundleName,
+		PackageName:    bundle.PackageName,
+		BundleImage:    bundle.BundleImage,
+		Version:        bundle.Bundle.Version,
+		DefaultChannel: bundle.Bundle.DefaultChannel,
+		Channels:       bundle.Bundle.Channels,
+		Result:         bundle.Result,
+		Reason:         bundle.Reason,
+		Message:        bundle.Message,
+		Error:          bundle.Error,
+		ClusterVersion: bundle.ClusterVersion,
+		PoolName:       runFlags.Pool.Name,
+		ClaimName:      bundle.ClaimName,
+		JobName:        bundle.JobName,
+	}
+
+	if len(bundle.PoolName) > 0 {
+		bundleReport.PoolName = bundle.PoolName
+	}
+
+	if duration := bundle.Duration(); duration > 0 {
+		bundleReport.Duration = duration.String()
+	}
+
+	if len(bundle.JobName) == 0 {
+		return bundleReport
+	}
+
+	if artifactsDir := artifacts.RunDir(runFlags.ArtifactsDir, bundle.RunID); len(artifactsDir) > 0 {
+		bundleReport.ArtifactsDir = filepath.Join(artifactsDir, bundle.BundleName, bundle.JobName)
+	}
+
+	if runFlags.UploadArtifacts {
+		bundleReport.ArtifactsURL = fmt.Sprintf("s3://%s/%s", runFlags.BucketName,
+			artifacts.ObjectPrefix(bundle.RunID, bundle.BundleName, bundle.JobName))
+	}
+
+	return bundleReport
+}
+
+// IsValidOutputFormat returns true when the report can be written in the format
+func IsValidOutputFormat(format string) bool {
+	for _, f := range OutputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the report in each format as report-<run-id>.<ext> in the output path and returns the file paths
+func (r Report) Write(outputPath string, formats []string) ([]string, error) {
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create the output path %s : %s", outputPath, err)
+	}
+
+	var paths []string
+	for _, format := range formats {
+		data, err := r.Render(format)
+		if err != nil {
+			return paths, err
+		}
+
+		reportPath := filepath.Join(outputPath, fmt.Sprintf("%s-%s.%s", reportName, r.RunID, fileExtensions[format]))
+		if err := os.WriteFile(reportPath, data, 0644); err != nil {
+			return paths, fmt.Errorf("unable to write the report %s : %s", reportPath, err)
+		}
+		paths = append(paths, reportPath)
+	}
+
+	return paths, nil
+}
+
+// Render returns the report in the format
+func (r Report) Render(format string) ([]byte, error) {
+	var out bytes.Buffer
+
+	switch format {
+	case JSON:
+		data, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal the report : %s", err)
+		}
+		return data, nil
+	case Markdown:
+		tmpl, err := template.New(Markdown).Funcs(template.FuncMap{
+			"cell":      markdownCell,
+			"channels":  channels,
+			"reason":    reason,
+			"artifacts": markdownArtifacts,
+		}).Parse(markdownTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the markdown report template : %s", err)
+		}
+		if err := tmpl.Execute(&out, r); err != nil {
+			return nil, fmt.Errorf("unable to render the markdown report : %s", err)
+		}
+	case HTML:
+		tmpl, err := htmltemplate.New(HTML).Funcs(htmltemplate.FuncMap{
+			"channels": channels,
+			"reason":   reason,
+		}).Parse(htmlTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the html report template : %s", err)
+		}
+		if err := tmpl.Execute(&out, r); err != nil {
+			return nil, fmt.Errorf("unable to render the html report : %s", err)
+		}
+	case JUnit:
+		return r.JUnit().Marshal()
+	default:
+		return nil, fmt.Errorf("unsupported report format %s", format)
+	}
+
+	return out.Bytes(), nil
+}
+
+// channels lists the channels of the bundle, marking the default one
+func channels(bundle BundleReport) string {
+	var names []string
+	for _, channel := range bundle.Channels {
+		if channel == bundle.DefaultChannel {
+			channel += " (default)"
+		}
+		names = append(names, channel)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// reason explains the result of the bundle with the Job failure reason or the orchestration error
+func reason(bundle BundleReport) string {
+	if len(bundle.Error) > 0 {
+		return bundle.Error
+	}
+
+	if len(bundle.Reason) > 0 && len(bundle.Message) > 0 {
+		return fmt.Sprintf("%s: %s", bundle.Reason, bundle.Message)
+	}
+
+	return bundle.Reason + bundle.Message
+}
+
+func markdownArtifacts(bundle BundleReport) string {
+	var links []string
+	if len(bundle.ArtifactsDir) > 0 {
+		links = append(links, fmt.Sprintf("[local](file://%s)", bundle.ArtifactsDir))
+	}
+	if len(bundle.ArtifactsURL) > 0 {
+		links = append(links, fmt.Sprintf("`%s`", bundle.ArtifactsURL))
+	}
+
+	return strings.Join(links, " ")
+}
+
+// markdownCell keeps the value within its table cell
+func markdownCell(value interface{}) string {
+	cell := strings.ReplaceAll(fmt.Sprint(value), "|", "\\|")
+	return strings.Join(strings.Fields(cell), " ")
+}
+
+// JUnit returns the report as JUnit test suites, one per package with a test case per bundle
+func (r Report) JUnit() JUnitTestSuites {
+	multiplePools := len(runPools(r)) > 1
+
+	var suites []JUnitTestSuite
+	for _, packageReport := range r.Packages {
+		var cases []junitCase
+		for _, bundle := range packageReport.Bundles {
+			duration, _ := time.ParseDuration(bundle.Duration)
+			message := bundle.Message
+			if len(bundle.Error) > 0 {
+				message = bundle.Error
+			}
+
+			// a bundle audited on several pools has a test case per pool
+			name := bundle.BundleName
+			if multiplePools {
+				name = fmt.Sprintf("%s on %s", bundle.BundleName, bundle.PoolName)
+			}
+
+			cases = append(cases, junitCase{
+				className: packageReport.PackageName,
+				name:      name,
+				result:    bundle.Result,
+				reason:    bundle.Reason,
+				message:   message,
+				duration:  duration,
+				logsDir:   bundle.ArtifactsDir,
+			})
+		}
+
+		suites = append(suites, newJUnitTestSuite(packageReport.PackageName, cases))
+	}
+
+	return newJUnitTestSuites(fmt.Sprintf("audit run %s", r.RunID), suites)
+}
+
+// runPools returns the names of the pools the bundles of the report were audited on
+func runPools(r Report) map[string]bool {
+	pools := map[string]bool{}
+	for _, packageReport := range r.Packages {
+		for _, bundle := range packageReport.Bundles {
+			pools[bundle.PoolName] = true
+		}
+	}
+
+	return pools
+}
+
+// WriteRunJUnit writes the JUnit XML report of the run to the path
+func WriteRunJUnit(store *state.Store, runID, path string) error {
+	runReport, err := NewReport(store, runID)
+	if err != nil {
+		return err
+	}
+
+	return runReport.JUnit().WriteFile(path)
+}
+
+// SuitesJUnit returns the results of the suites run against the bundle as JUnit test suites, with a test case
+// per suite
+func SuitesJUnit(flags orchestrate.JobFlags, results []suite.Result) JUnitTestSuites {
+	bundleName := flags.BundleName
+	if len(bundleName) == 0 {
+		bundleName = flags.Name
+	}
+
+	var cases []junitCase
+	for _, result := range results {
+		cases = append(cases, junitCase{
+			className: bundleName,
+			name:      result.Suite,
+			result:    result.Result,
+			reason:    result.Reason,
+			message:   result.Message,
+			duration:  result.Duration,
+			logsDir:   artifacts.JobDir(flags.Artifacts(), result.JobName),
+		})
+	}
+
+	return newJUnitTestSuites(bundleName, []JUnitTestSuite{newJUnitTestSuite(bundleName, cases)})
+}
+
+// Marshal returns the JUnit XML document
+func (s JUnitTestSuites) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the JUnit report : %s", err)
+	}
+
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// WriteFile writes the JUnit XML document to the path
+func (s JUnitTestSuites) WriteFile(path string) error {
+	data, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("unable to write the JUnit report %s : %s", path, err)
+	}
+
+	return nil
+}
+
+func newJUnitTestSuites(name string, suites []JUnitTestSuite) JUnitTestSuites {
+	testSuites := JUnitTestSuites{Name: name, Suites: suites}
+
+	var seconds float64
+	for _, suite := range suites {
+		testSuites.Tests += suite.Tests
+		testSuites.Failures += suite.Failures
+		testSuites.Errors += suite.Errors
+		testSuites.Skipped += suite.Skipped
+		suiteSeconds, _ := strconv.ParseFloat(suite.Time, 64)
+		seconds += suiteSeconds
+	}
+	testSuites.Time = fmt.Sprintf("%.3f", seconds)
+
+	return testSuites
+}
+
+func newJUnitTestSuite(name string, cases []junitCase) JUnitTestSuite {
+	suite := JUnitTestSuite{Name: name}
+
+	var total time.Duration
+	for _, c := range cases {
+		testCase := JUnitTestCase{
+			Name:      c.name,
+			ClassName: c.className,
+			Time:      fmt.Sprintf("%.3f", c.duration.Seconds()),
+		}
+
+		switch c.result {
+		case batchv1.JobComplete:
+		case batchv1.JobFailed:
+			suite.Failures++
+			testCase.Failure = &JUnitFailure{
+				Message:  failureMessage(c),
+				Type:     c.reason,
+				Contents: logExcerpt(c.logsDir),
+			}
+		case "":
+			suite.Skipped++
+			testCase.Skipped = &JUnitSkipped{Message: "not audited yet"}
+		default:
+			suite.Errors++
+			testCase.Error = &JUnitFailure{
+				Message:  failureMessage(c),
+				Type:     string(c.result),
+				Contents: logExcerpt(c.logsDir),
+			}
+		}
+
+		suite.Tests++
+		total += c.duration
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	return suite
+}
+
+func failureMessage(c junitCase) string {
+	if len(c.reason) > 0 && len(c.message) > 0 {
+		return xmlText(fmt.Sprintf("%s: %s", c.reason, c.message))
+	}
+
+	return xmlText(c.reason + c.message)
+}
+
+// xmlText removes the ANSI escape sequences of the text, e.g. the colors of the audit logs, and the characters
+// which are not allowed in XML 1.0 documents
+func xmlText(text string) string {
+	text = ansiEscapes.ReplaceAllString(text, "")
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+		case r >= 0x20 && r <= 0xD7FF:
+		case r >= 0xE000 && r <= 0xFFFD && r != utf8.RuneError:
+		case r >= 0x10000 && r <= 0x10FFFF:
+		default:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// logExcerpt returns the last lines of each log saved in the artifacts directory
+func logExcerpt(dir string) string {
+	if len(dir) == 0 {
+		return ""
+	}
+
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return ""
+	}
+
+	var excerpt strings.Builder
+	for _, logPath := range logs {
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(lines) > junitLogLines {
+			lines = lines[len(lines)-junitLogLines:]
+		}
+
+		fmt.Fprintf(&excerpt, "==> %s <==\n%s\n", filepath.Base(logPath), strings.Join(lines, "\n"))
+	}
+
+	return xmlText(excerpt.String())
+}
+
+// CompareReports matches the bundles of the runs by package and channel and lists the regressions, the fixes
+// and the channels added or removed in the head run
+func CompareReports(base, head Report) Comparison {
+	comparison := Comparison{Base: base.RunID, Head: head.RunID}
+
+	baseChannels := latestByChannel(base)
+	headChannels := latestByChannel(head)
+
+	for key, headBundle := range headChannels {
+		change := BundleChange{PackageName: key.packageName, Channel: key.channel, PoolName: key.poolName,
+			Head: headBundle}
+
+		baseBundle, ok := baseChannels[key]
+		if !ok {
+			comparison.Added = append(comparison.Added, change)
+			continue
+		}
+		change.Base = baseBundle
+
+		switch {
+		case passed(baseBundle) && failed(headBundle):
+			comparison.Regressions = append(comparison.Regressions, change)
+		case failed(baseBundle) && passed(headBundle):
+			comparison.Fixes = append(comparison.Fixes, change)
+		default:
+			comparison.Unchanged++
+		}
+	}
+
+	for key, baseBundle := range baseChannels {
+		if _, ok := headChannels[key]; !ok {
+			comparison.Removed = append(comparison.Removed, BundleChange{
+				PackageName: key.packageName,
+				Channel:     key.channel,
+				PoolName:    key.poolName,
+				Base:        baseBundle,
+			})
+		}
+	}
+
+	for _, changes := range [][]BundleChange{comparison.Regressions, comparison.Fixes, comparison.Added,
+		comparison.Removed} {
+		sortChanges(changes)
+	}
+
+	return comparison
+}
+
+// Print writes the comparison in the format, a table of the changed channels for text
+func (c Comparison) Print(out io.Writer, format string) error {
+	if format == JSON {
+		data, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return fmt.Errorf("unable to marshal the comparison : %s", err)
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	fmt.Fprintf(out, "Comparing run %s to run %s: %d regressions, %d fixes, %d added, %d removed, %d unchanged\n\n",
+		c.Head, c.Base, len(c.Regressions), len(c.Fixes), len(c.Added), len(c.Removed), c.Unchanged)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tPACKAGE\tCHANNEL\tPOOL\tBASE BUNDLE\tBASE RESULT\tHEAD BUNDLE\tHEAD RESULT")
+	for _, group := range []struct {
+		kind    string
+		changes []BundleChange
+	}{
+		{regression, c.Regressions},
+		{fix, c.Fixes},
+		{added, c.Added},
+		{removed, c.Removed},
+	} {
+		for _, change := range group.changes {
+			baseName, baseResult := changeSide(change.Base)
+			headName, headResult := changeSide(change.Head)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", group.kind, change.PackageName, change.Channel,
+				change.PoolName, baseName, baseResult, headName, headResult)
+		}
+	}
+
+	return w.Flush()
+}
+
+// IsValidCompareOutputFormat returns true when the comparison can be printed in the format
+func IsValidCompareOutputFormat(format string) bool {
+	for _, f := range CompareOutputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// latestByChannel returns the bundle with the highest version of each channel of each package of the report
+func latestByChannel(r Report) map[channelKey]*BundleReport {
+	latest := map[channelKey]*BundleReport{}
+	for _, packageReport := range r.Packages {
+		for i := range packageReport.Bundles {
+			bundle := &packageReport.Bundles[i]
+
+			channels := bundle.Channels
+			if len(channels) == 0 {
+				// runs recorded without the index data only know the package
+				channels = []string{""}
+			}
+
+			for _, channel := range channels {
+				key := channelKey{packageName: bundle.PackageName, channel: channel, poolName: bundle.PoolName}
+				if current, ok := latest[key]; !ok || newerBundle(bundle, current) {
+					latest[key] = bundle
+				}
+			}
+		}
+	}
+
+	return latest
+}
+
+// newerBundle compares the bundles by semantic version, and by name when a version is not valid
+func newerBundle(bundle, than *BundleReport) bool {
+	version, thanVersion := "v"+bundle.Version, "v"+than.Version
+	if semver.IsValid(version) && semver.IsValid(thanVersion) {
+		if c := semver.Compare(version, thanVersion); c != 0 {
+			return c > 0
+		}
+	}
+
+	return bundle.BundleName > than.BundleName
+}
+
+func passed(bundle *BundleReport) bool {
+	return bundle.Result == batchv1.JobComplete
+}
+
+// failed is true for the audits which finished without success, not for the bundles still pending
+func failed(bundle *BundleReport) bool {
+	return len(bundle.Result) > 0 && bundle.Result != batchv1.JobComplete
+}
+
+func changeSide(bundle *BundleReport) (string, string) {
+	if bundle == nil {
+		return "-", "-"
+	}
+
+	result := string(bundle.Result)
+	if len(result) == 0 {
+		result = "pending"
+	}
+
+	return bundle.BundleName, result
+}
+
+func sortChanges(changes []BundleChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].PackageName != changes[j].PackageName {
+			return changes[i].PackageName < changes[j].PackageName
+		}
+		if changes[i].Channel != changes[j].Channel {
+			return changes[i].Channel < changes[j].Channel
+		}
+		return changes[i].PoolName < changes[j].PoolName
+	})
+}
@@ -0,0 +1,300 @@
+package report_test
+
+import (
+	"audit-tool-orchestrator/pkg/report"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// update rewrites the golden files with the rendered reports: go test ./pkg/report -update
+var update = flag.Bool("update", false, "update the golden files of the reports")
+
+// testReport has a bundle of each outcome, the failed one with an audit log colored by the terminal
+func testReport() report.Report {
+	return report.Report{
+		RunID:      "20220502-100000-a1b2c3",
+		IndexImage: "registry.redhat.io/redhat/community-operator-index:v4.10",
+		GenerateAt: "2022-05-02",
+		Summary:    report.Summary{Bundles: 4, Succeeded: 1, Failed: 2, Pending: 1},
+		Packages: []report.PackageReport{
+			{
+				PackageName:    "etcd",
+				DefaultChannel: "clusterwide-alpha",
+				Bundles: []report.BundleReport{
+					{
+						BundleName:     "etcd.v0.9.2",
+						PackageName:    "etcd",
+						BundleImage:    "quay.io/operatorhubio/etcd:v0.9.2",
+						Version:        "0.9.2",
+						DefaultChannel: "clusterwide-alpha",
+						Channels:       []string{"clusterwide-alpha", "singlenamespace-alpha"},
+						Result:         batchv1.JobFailed,
+						Reason:         "BackoffLimitExceeded",
+						Message:        "Job has reached the specified backoff limit",
+						Duration:       "12m30s",
+						ClusterVersion: "4.10.3",
+						PoolName:       "ocp-4-10-aws",
+						JobName:        "ato-etcd-v0-9-2",
+						ArtifactsDir:   "testdata/artifacts/etcd.v0.9.2/ato-etcd-v0-9-2",
+						ArtifactsURL:   "s3://audit/20220502-100000-a1b2c3/etcd.v0.9.2/artifacts/ato-etcd-v0-9-2",
+					},
+					{
+						BundleName:     "etcd.v0.9.4",
+						PackageName:    "etcd",
+						BundleImage:    "quay.io/operatorhubio/etcd:v0.9.4",
+						Version:        "0.9.4",
+						DefaultChannel: "clusterwide-alpha",
+						Channels:       []string{"clusterwide-alpha"},
+						Result:         batchv1.JobComplete,
+						Duration:       "8m5s",
+						ClusterVersion: "4.10.3",
+						PoolName:       "ocp-4-10-aws",
+						JobName:        "ato-etcd-v0-9-4",
+					},
+				},
+			},
+			{
+				PackageName:    "prometheus",
+				DefaultChannel: "beta",
+				Bundles: []report.BundleReport{
+					{
+						BundleName:     "prometheusoperator.0.47.0",
+						PackageName:    "prometheus",
+						BundleImage:    "quay.io/operatorhubio/prometheus:v0.47.0",
+						Version:        "0.47.0",
+						DefaultChannel: "beta",
+						Channels:       []string{"beta"},
+						Result:         "Error",
+						Error:          "unable to claim a cluster | ClusterClaim ato-claim-0 is pending",
+						PoolName:       "ocp-4-10-aws",
+					},
+					{
+						BundleName:     "prometheusoperator.0.47.1",
+						PackageName:    "prometheus",
+						BundleImage:    "quay.io/operatorhubio/prometheus:v0.47.1",
+						Version:        "0.47.1",
+						DefaultChannel: "beta",
+						Channels:       []string{"beta"},
+						PoolName:       "ocp-4-10-aws",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		golden string
+	}{
+		{name: "json", format: "json", golden: "report.json"},
+		{name: "markdown", format: report.Markdown, golden: "report.md"},
+		{name: "html", format: report.HTML, golden: "report.html"},
+		{name: "junit", format: report.JUnit, golden: "report.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testReport().Render(tt.format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("unable to read the golden file: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Render() = \n%s\nwant the golden file %s\n%s", got, golden, want)
+			}
+		})
+	}
+
+	if _, err := testReport().Render("pdf"); err == nil {
+		t.Errorf("Render() error = nil for an unsupported format")
+	}
+}
+
+func TestRenderJUnitIsValidXML(t *testing.T) {
+	data, err := testReport().Render(report.JUnit)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	// the decoder rejects the characters which are not allowed in XML 1.0 documents
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := decoder.Token(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("Render() is not valid XML: %v", err)
+		}
+	}
+
+	var suites report.JUnitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("unable to decode the JUnit report: %v", err)
+	}
+	if suites.Tests != 4 || suites.Failures != 1 || suites.Errors != 1 || suites.Skipped != 1 {
+		t.Errorf("Render() = %d tests, %d failures, %d errors and %d skipped, want 4, 1, 1 and 1",
+			suites.Tests, suites.Failures, suites.Errors, suites.Skipped)
+	}
+
+	failure := suites.Suites[0].TestCases[0].Failure
+	if failure == nil {
+		t.Fatalf("Render() has no failure for the bundle etcd.v0.9.2")
+	}
+	if want := "==> audit.log <==\n"; !bytes.HasPrefix([]byte(failure.Contents), []byte(want)) {
+		t.Errorf("Render() failure = %q, want the excerpt of the audit log", failure.Contents)
+	}
+	if bytes.ContainsAny([]byte(failure.Contents), "\x1b\x00\x07") {
+		t.Errorf("Render() failure = %q, want the escapes and control characters removed", failure.Contents)
+	}
+}
+
+// auditedBundle returns the report of the bundle of the package channel audited on the pool
+func auditedBundle(packageName, channel, pool, version string, result batchv1.JobConditionType) report.BundleReport {
+	return report.BundleReport{
+		BundleName:  packageName + ".v" + version,
+		PackageName: packageName,
+		Version:     version,
+		Channels:    []string{channel},
+		Result:      result,
+		PoolName:    pool,
+	}
+}
+
+// runReport returns the report of the run with a package per bundle
+func runReport(runID string, bundles ...report.BundleReport) report.Report {
+	r := report.Report{RunID: runID}
+	for _, bundle := range bundles {
+		r.Packages = append(r.Packages, report.PackageReport{PackageName: bundle.PackageName,
+			Bundles: []report.BundleReport{bundle}})
+	}
+	return r
+}
+
+// changeNames returns the base and head bundles of the changes, - when not audited in the run
+func changeNames(changes []report.BundleChange) []string {
+	var names []string
+	for _, change := range changes {
+		base, head := "-", "-"
+		if change.Base != nil {
+			base = change.Base.BundleName
+		}
+		if change.Head != nil {
+			head = change.Head.BundleName
+		}
+		names = append(names, fmt.Sprintf("%s/%s/%s: %s -> %s", change.PackageName, change.Channel,
+			change.PoolName, base, head))
+	}
+	return names
+}
+
+func TestCompareReports(t *testing.T) {
+	const aws, gcp = "ocp-4-10-aws", "ocp-4-10-gcp"
+	complete, failed := batchv1.JobComplete, batchv1.JobFailed
+
+	tests := []struct {
+		name            string
+		base            report.Report
+		head            report.Report
+		wantRegressions []string
+		wantFixes       []string
+		wantAdded       []string
+		wantRemoved     []string
+		wantUnchanged   int
+	}{
+		{
+			name:          "same results",
+			base:          runReport("base", auditedBundle("etcd", "alpha", aws, "0.9.4", complete)),
+			head:          runReport("head", auditedBundle("etcd", "alpha", aws, "0.9.4", complete)),
+			wantUnchanged: 1,
+		},
+		{
+			name:            "regression of the newer bundle",
+			base:            runReport("base", auditedBundle("etcd", "alpha", aws, "0.9.4", complete)),
+			head:            runReport("head", auditedBundle("etcd", "alpha", aws, "0.9.10", failed)),
+			wantRegressions: []string{"etcd/alpha/ocp-4-10-aws: etcd.v0.9.4 -> etcd.v0.9.10"},
+		},
+		{
+			name: "fix compared to the latest bundle of the channel only",
+			base: runReport("base", auditedBundle("etcd", "alpha", aws, "0.9.2", complete),
+				auditedBundle("etcd", "alpha", aws, "0.9.4", failed)),
+			head:      runReport("head", auditedBundle("etcd", "alpha", aws, "0.9.4", complete)),
+			wantFixes: []string{"etcd/alpha/ocp-4-10-aws: etcd.v0.9.4 -> etcd.v0.9.4"},
+		},
+		{
+			name: "pools compared apart",
+			base: runReport("base", auditedBundle("etcd", "alpha", aws, "0.9.4", complete),
+				auditedBundle("etcd", "alpha", gcp, "0.9.4", failed)),
+			head: runReport("head", auditedBundle("etcd", "alpha", aws, "0.9.4", failed),
+				auditedBundle("etcd", "alpha", gcp, "0.9.4", complete)),
+			wantRegressions: []string{"etcd/alpha/ocp-4-10-aws: etcd.v0.9.4 -> etcd.v0.9.4"},
+			wantFixes:       []string{"etcd/alpha/ocp-4-10-gcp: etcd.v0.9.4 -> etcd.v0.9.4"},
+		},
+		{
+			name: "channels and pools added and removed",
+			base: runReport("base", auditedBundle("etcd", "alpha", aws, "0.9.4", complete),
+				auditedBundle("prometheus", "beta", aws, "0.47.0", complete)),
+			head: runReport("head", auditedBundle("etcd", "alpha", aws, "0.9.4", complete),
+				auditedBundle("etcd", "alpha", gcp, "0.9.4", complete),
+				auditedBundle("jaeger", "stable", aws, "1.34.1", failed)),
+			wantAdded: []string{"etcd/alpha/ocp-4-10-gcp: - -> etcd.v0.9.4",
+				"jaeger/stable/ocp-4-10-aws: - -> jaeger.v1.34.1"},
+			wantRemoved:   []string{"prometheus/beta/ocp-4-10-aws: prometheus.v0.47.0 -> -"},
+			wantUnchanged: 1,
+		},
+		{
+			name:          "pending bundle is neither a regression nor a fix",
+			base:          runReport("base", auditedBundle("etcd", "alpha", aws, "0.9.4", complete)),
+			head:          runReport("head", auditedBundle("etcd", "alpha", aws, "0.9.4", "")),
+			wantUnchanged: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := report.CompareReports(tt.base, tt.head)
+			if got.Base != tt.base.RunID || got.Head != tt.head.RunID {
+				t.Errorf("CompareReports() compares %s to %s, want %s to %s", got.Head, got.Base,
+					tt.head.RunID, tt.base.RunID)
+			}
+			for _, check := range []struct {
+				kind    string
+				changes []report.BundleChange
+				want    []string
+			}{
+				{"regressions", got.Regressions, tt.wantRegressions},
+				{"fixes", got.Fixes, tt.wantFixes},
+				{"added", got.Added, tt.wantAdded},
+				{"removed", got.Removed, tt.wantRemoved},
+			} {
+				if names := changeNames(check.changes); !reflect.DeepEqual(names, check.want) {
+					t.Errorf("CompareReports() %s = %v, want %v", check.kind, names, check.want)
+				}
+			}
+			if got.Unchanged != tt.wantUnchanged {
+				t.Errorf("CompareReports() unchanged = %d, want %d", got.Unchanged, tt.wantUnchanged)
+			}
+		})
+	}
+}
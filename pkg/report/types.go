@@ -0,0 +1,152 @@
+package report
+
+import (
+	"encoding/xml"
+	batchv1 "k8s.io/api/batch/v1"
+	"time"
+)
+
+type ReportFlags struct {
+	RunID         string   `json:"runId"`
+	StateDB       string   `json:"stateDB"`
+	OutputPath    string   `json:"outputPath"`
+	OutputFormats []string `json:"outputFormats"`
+}
+
+// junitCase is the outcome of one audit as reported in a JUnit test case
+type junitCase struct {
+	className string
+	name      string
+	result    batchv1.JobConditionType
+	reason    string
+	message   string
+	duration  time.Duration
+	logsDir   string
+}
+
+type CompareFlags struct {
+	Base         string `json:"base"`
+	Head         string `json:"head"`
+	StateDB      string `json:"stateDB"`
+	OutputFormat string `json:"outputFormat"`
+}
+
+// Comparison lists how the audit outcome of the bundles changed from the base run to the head run. The bundles
+// are matched by package and channel.
+type Comparison struct {
+	Base        string         `json:"base"`
+	Head        string         `json:"head"`
+	Regressions []BundleChange `json:"regressions"`
+	Fixes       []BundleChange `json:"fixes"`
+	Added       []BundleChange `json:"added"`
+	Removed     []BundleChange `json:"removed"`
+	Unchanged   int            `json:"unchanged"`
+}
+
+// BundleChange holds the bundles of a package channel in the base and head runs, nil when not audited in the run
+type BundleChange struct {
+	PackageName string        `json:"packageName"`
+	Channel     string        `json:"channel"`
+	PoolName    string        `json:"poolName,omitempty"`
+	Base        *BundleReport `json:"base,omitempty"`
+	Head        *BundleReport `json:"head,omitempty"`
+}
+
+// channelKey identifies a channel of a package across runs
+type channelKey struct {
+	packageName string
+	channel     string
+	poolName    string
+}
+
+// Report is the combined audit outcome of the bundles of a run, grouped by package
+type Report struct {
+	RunID      string          `json:"runId"`
+	IndexImage string          `json:"image"`
+	GenerateAt string          `json:"generateAt"`
+	Summary    Summary         `json:"summary"`
+	Packages   []PackageReport `json:"packages"`
+}
+
+// Summary counts the bundles of the report by outcome
+type Summary struct {
+	Bundles   int `json:"bundles"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Pending   int `json:"pending"`
+}
+
+// PackageReport holds the bundles of one package sorted by name
+type PackageReport struct {
+	PackageName    string         `json:"packageName"`
+	DefaultChannel string         `json:"defaultChannel"`
+	Bundles        []BundleReport `json:"bundles"`
+}
+
+// BundleReport is the audit outcome of one bundle with the index data and where its artifacts are
+type BundleReport struct {
+	BundleName     string                   `json:"bundleName"`
+	PackageName    string                   `json:"packageName"`
+	BundleImage    string                   `json:"bundleImage"`
+	Version        string                   `json:"version"`
+	DefaultChannel string                   `json:"defaultChannel"`
+	Channels       []string                 `json:"channels"`
+	Result         batchv1.JobConditionType `json:"result"`
+	Reason         string                   `json:"reason,omitempty"`
+	Message        string                   `json:"message,omitempty"`
+	Error          string                   `json:"error,omitempty"`
+	Duration       string                   `json:"duration,omitempty"`
+	ClusterVersion string                   `json:"clusterVersion,omitempty"`
+	PoolName       string                   `json:"poolName,omitempty"`
+	ClaimName      string                   `json:"claimName,omitempty"`
+	JobName        string                   `json:"jobName,omitempty"`
+	// ArtifactsDir is the local directory of the artifacts of the audit Job
+	ArtifactsDir string `json:"artifactsDir,omitempty"`
+	// ArtifactsURL locates the artifacts uploaded to the bucket, as s3://<bucket>/<prefix>
+	ArtifactsURL string `json:"artifactsUrl,omitempty"`
+}
+
+// JUnitTestSuites is the root element of the JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite groups the test cases of a package, or the suites run against a bundle
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is the audit of one bundle, or one suite run against a bundle
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Error     *JUnitFailure `xml:"error,omitempty"`
+	Skipped   *JUnitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitFailure holds the Job condition reason and message with an excerpt of the audit logs
+type JUnitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",cdata"`
+}
+
+type JUnitSkipped struct {
+	Message string `xml:"message,attr"`
+}
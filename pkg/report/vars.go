@@ -0,0 +1,50 @@
+package report
+
+import (
+	. "audit-tool-orchestrator/pkg"
+	_ "embed"
+	"regexp"
+)
+
+const Markdown = "markdown"
+const HTML = "html"
+const JUnit = "junit"
+
+// OutputFormats are the formats the report can be written in
+var OutputFormats = []string{JSON, Markdown, HTML, JUnit}
+
+// fileExtensions of the report files by format
+var fileExtensions = map[string]string{
+	JSON:     "json",
+	Markdown: "md",
+	HTML:     "html",
+	JUnit:    "xml",
+}
+
+// ansiEscapes match the escape sequences of terminals, such as colors, which are left out of the JUnit reports
+var ansiEscapes = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// junitLogLines is the number of lines taken from the end of each audit log into the JUnit failures
+const junitLogLines = 50
+
+const DefaultOutputPath = "/tmp"
+const reportName = "report"
+
+// generateAtLayout dates the report like the reports of the audit-tool
+const generateAtLayout = "2006-01-02"
+
+//go:embed templates/report.md
+var markdownTemplate string
+
+//go:embed templates/report.html
+var htmlTemplate string
+
+const Text = "text"
+
+// CompareOutputFormats are the formats the comparison of two runs can be printed in
+var CompareOutputFormats = []string{Text, JSON}
+
+const regression = "regression"
+const fix = "fix"
+const added = "added"
+const removed = "removed"
@@ -0,0 +1,128 @@
+package results
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"audit-tool-orchestrator/pkg/state"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// BucketNameForRun returns the bucket of the flags or, when not set, the one the run was started with
+func BucketNameForRun(store *state.Store, runID, bucketName string) (string, error) {
+	if len(bucketName) > 0 {
+		return bucketName, nil
+	}
+
+	run, err := store.GetRun(runID)
+	if err != nil {
+		return "", err
+	}
+
+	runFlags := orchestrate.RunFlags{}
+	if err := json.Unmarshal([]byte(run.Flags), &runFlags); err != nil {
+		return "", fmt.Errorf("unable to read the flags of run %s : %s", runID, err)
+	}
+
+	if len(runFlags.BucketName) == 0 {
+		return "", fmt.Errorf("run %s was started without bucket; set one with --bucket-name", runID)
+	}
+
+	return runFlags.BucketName, nil
+}
+
+// ListRun returns the objects stored in the bucket for each bundle of the run, or only for the given bundles
+func ListRun(ctx context.Context, client *bucket.Client, store *state.Store, runID string,
+	bundles []string) ([]BundleResults, error) {
+	return collectRun(ctx, store, runID, bundles, func(bundleName string) (BundleResults, error) {
+		objects, err := client.List(ctx, bundlePrefix(runID, bundleName))
+		return BundleResults{BundleName: bundleName, Objects: objects}, err
+	})
+}
+
+// FetchRun downloads the objects stored in the bucket for each bundle of the run, or only for the given bundles,
+// into <outputDir>/<run-id>/<bundle-name>
+func FetchRun(ctx context.Context, client *bucket.Client, store *state.Store, runID, outputDir string,
+	bundles []string) ([]BundleResults, error) {
+	return collectRun(ctx, store, runID, bundles, func(bundleName string) (BundleResults, error) {
+		dir := filepath.Join(outputDir, runID, bundleName)
+		objects, err := client.Download(ctx, bundlePrefix(runID, bundleName), dir)
+		return BundleResults{BundleName: bundleName, Objects: objects, Dir: dir}, err
+	})
+}
+
+// Print writes a table with the number of objects of each bundle and where they were downloaded
+func Print(out io.Writer, results []BundleResults) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "BUNDLE\tOBJECTS\tDIR")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", result.BundleName, len(result.Objects), result.Dir)
+	}
+
+	return w.Flush()
+}
+
+// PrintObjects writes a table with every object of each bundle
+func PrintObjects(out io.Writer, results []BundleResults) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "BUNDLE\tKEY\tSIZE\tLAST MODIFIED")
+	for _, result := range results {
+		for _, object := range result.Objects {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", result.BundleName, object.Key, object.Size, object.LastModified)
+		}
+	}
+
+	return w.Flush()
+}
+
+func collectRun(ctx context.Context, store *state.Store, runID string, bundles []string,
+	collect func(bundleName string) (BundleResults, error)) ([]BundleResults, error) {
+	recorded, err := store.Bundles(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("run %s not found in the state database", runID)
+	}
+
+	selected := map[string]bool{}
+	for _, name := range bundles {
+		selected[name] = true
+	}
+
+	// the objects are stored by run and bundle, whichever the pools the bundle was audited on
+	collected := map[string]bool{}
+	var results []BundleResults
+	for _, bundle := range recorded {
+		if len(selected) > 0 && !selected[bundle.BundleName] || collected[bundle.BundleName] {
+			continue
+		}
+		collected[bundle.BundleName] = true
+
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+
+		result, err := collect(bundle.BundleName)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// bundlePrefix returns the prefix of the objects of the bundle in the run, as written for the audit result and
+// the artifacts
+func bundlePrefix(runID, bundleName string) string {
+	return path.Join(runID, bundleName) + "/"
+}
@@ -0,0 +1,186 @@
+package results_test
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/bucket/fake"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/results"
+	"audit-tool-orchestrator/pkg/state"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// objects holds the results and artifacts of the bundle etcd.v0.9.4 in two runs
+var objects = map[string][]byte{
+	"run-1/etcd.v0.9.4/result.json":                     []byte(`{"result":"Complete"}`),
+	"run-1/etcd.v0.9.4/artifacts/ato-etcd/job.yaml":     []byte("kind: Job"),
+	"run-1/etcd.v0.9.4/artifacts/ato-etcd/events.yaml":  []byte("kind: EventList"),
+	"run-2/etcd.v0.9.4/result.json":                     []byte(`{"result":"Failed"}`),
+	"run-2/etcd.v0.9.4/artifacts/ato-etcd/job.yaml":     []byte("kind: Job"),
+	"run-1/etcd.v0.9.40/result.json":                    []byte(`{"result":"Complete"}`),
+	"run-1/prometheus.v0.47.0/result.json":              []byte(`{"result":"Complete"}`),
+	"run-1/prometheus.v0.47.0/artifacts/ato-p/job.yaml": []byte("kind: Job"),
+}
+
+func newStore(t *testing.T) *state.Store {
+	t.Helper()
+
+	store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("unable to open the state database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	for _, runID := range []string{"run-1", "run-2"} {
+		targets := []state.Target{
+			{Bundle: index.Bundle{Name: "etcd.v0.9.4", PackageName: "etcd"}},
+			{Bundle: index.Bundle{Name: "prometheus.v0.47.0", PackageName: "prometheus"}},
+		}
+		if err := store.CreateRun(state.Run{ID: runID, CreatedAt: state.Now()}, targets); err != nil {
+			t.Fatalf("unable to create run %s: %v", runID, err)
+		}
+	}
+
+	return store
+}
+
+func newClient(t *testing.T) *bucket.Client {
+	t.Helper()
+
+	server := fake.NewServer("audit", objects)
+	t.Cleanup(server.Close)
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatalf("unable to create the bucket client: %v", err)
+	}
+
+	return client
+}
+
+func bundleKeys(bundles []results.BundleResults) map[string][]string {
+	keys := map[string][]string{}
+	for _, bundle := range bundles {
+		keys[bundle.BundleName] = []string{}
+		for _, object := range bundle.Objects {
+			keys[bundle.BundleName] = append(keys[bundle.BundleName], object.Key)
+		}
+	}
+
+	return keys
+}
+
+func TestListRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		runID   string
+		bundles []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:  "every bundle of the run",
+			runID: "run-1",
+			want: map[string][]string{
+				"etcd.v0.9.4": {
+					"run-1/etcd.v0.9.4/artifacts/ato-etcd/events.yaml",
+					"run-1/etcd.v0.9.4/artifacts/ato-etcd/job.yaml",
+					"run-1/etcd.v0.9.4/result.json",
+				},
+				"prometheus.v0.47.0": {
+					"run-1/prometheus.v0.47.0/artifacts/ato-p/job.yaml",
+					"run-1/prometheus.v0.47.0/result.json",
+				},
+			},
+		},
+		{
+			name:    "selected bundle of another run",
+			runID:   "run-2",
+			bundles: []string{"etcd.v0.9.4"},
+			want: map[string][]string{
+				"etcd.v0.9.4": {
+					"run-2/etcd.v0.9.4/artifacts/ato-etcd/job.yaml",
+					"run-2/etcd.v0.9.4/result.json",
+				},
+			},
+		},
+		{
+			name:  "bundle without objects in the run",
+			runID: "run-2",
+			want: map[string][]string{
+				"etcd.v0.9.4": {
+					"run-2/etcd.v0.9.4/artifacts/ato-etcd/job.yaml",
+					"run-2/etcd.v0.9.4/result.json",
+				},
+				"prometheus.v0.47.0": {},
+			},
+		},
+		{
+			name:    "unknown run",
+			runID:   "run-3",
+			wantErr: true,
+		},
+	}
+
+	store := newStore(t)
+	client := newClient(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := results.ListRun(context.Background(), client, store, tt.runID, tt.bundles)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if keys := bundleKeys(got); !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("ListRun() = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchRun(t *testing.T) {
+	store := newStore(t)
+	client := newClient(t)
+	outputDir := t.TempDir()
+
+	got, err := results.FetchRun(context.Background(), client, store, "run-2", outputDir, []string{"etcd.v0.9.4"})
+	if err != nil {
+		t.Fatalf("FetchRun() error = %v", err)
+	}
+
+	wantDir := filepath.Join(outputDir, "run-2", "etcd.v0.9.4")
+	if len(got) != 1 || got[0].Dir != wantDir {
+		t.Fatalf("FetchRun() = %+v, want the bundle etcd.v0.9.4 in %s", got, wantDir)
+	}
+
+	var files []string
+	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(wantDir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"artifacts/ato-etcd/job.yaml", "result.json"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("FetchRun() downloaded %v, want %v", files, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(wantDir, "result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"result":"Failed"}` {
+		t.Errorf("FetchRun() downloaded the result %s of another run", data)
+	}
+}
@@ -0,0 +1,22 @@
+package results
+
+import "audit-tool-orchestrator/pkg/bucket"
+
+type ResultsFlags struct {
+	RunID      string   `json:"runId"`
+	StateDB    string   `json:"stateDB"`
+	BucketName string   `json:"bucketName"`
+	OutputDir  string   `json:"outputDir"`
+	Bundles    []string `json:"bundles"`
+	Secure     bool     `json:"secure"`
+	// BucketCredentials tell where the credentials of the bucket are read from
+	BucketCredentials bucket.CredentialsFlags `json:"bucketCredentials"`
+}
+
+// BundleResults holds the objects stored in the bucket for one bundle of a run
+type BundleResults struct {
+	BundleName string          `json:"bundleName"`
+	Objects    []bucket.Object `json:"objects"`
+	// Dir is where the objects were downloaded, empty when they were only listed
+	Dir string `json:"dir,omitempty"`
+}
@@ -0,0 +1,4 @@
+package results
+
+// DefaultOutputDir is where the results of the runs are downloaded, under <run-id>/<bundle-name>
+const DefaultOutputDir = "results"
@@ -0,0 +1,222 @@
+package state
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/mattn/go-sqlite3"
+	batchv1 "k8s.io/api/batch/v1"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultDBPath returns the path of the state database in the user's home directory
+func DefaultDBPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return DefaultDBName
+	}
+
+	return filepath.Join(home, ".ato", DefaultDBName)
+}
+
+// Open opens, and creates when needed, the state database
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("unable to create the directory for the state database : %s", err)
+	}
+
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the state database %s : %s", path, err)
+	}
+
+	// sqlite allows one writer at a time
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{createRunsTable, createBundlesTable} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to create the state database schema : %s", err)
+		}
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// NewRunID returns an identifier for a new run based on the current time and a random suffix, so runs
+// started in the same second get different identifiers
+func NewRunID() string {
+	now := time.Now().UTC()
+	suffix := make([]byte, runIDSuffixLength)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%06d", now.Format("20060102-150405"), now.Nanosecond()/1000)
+	}
+
+	return fmt.Sprintf("%s-%s", now.Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
+// CreateRun records the run and the bundles it will audit, on the pool of each target
+func (s *Store) CreateRun(run Run, targets []Target) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to start transaction : %s", err)
+	}
+
+	_, err = sq.Insert("run").
+		Columns("id", "index_image", "flags", "created_at").
+		Values(run.ID, run.IndexImage, run.Flags, run.CreatedAt).
+		RunWith(tx).Exec()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("unable to record run %s : %s", run.ID, err)
+	}
+
+	for _, target := range targets {
+		bundle := target.Bundle
+		bundleData, err := json.Marshal(bundle)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to marshal bundle %s : %s", bundle.Name, err)
+		}
+
+		_, err = sq.Insert("run_bundle").
+			Columns("run_id", "bundle_name", "pool_name", "package_name", "bundle_image", "bundle_data",
+				"updated_at").
+			Values(run.ID, bundle.Name, target.PoolName, bundle.PackageName, bundle.BundleImage,
+				string(bundleData), Now()).
+			RunWith(tx).Exec()
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to record bundle %s for run %s : %s", bundle.Name, run.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRun returns the run recorded with the id
+func (s *Store) GetRun(id string) (Run, error) {
+	run := Run{}
+
+	err := sq.Select("id", "index_image", "flags", "created_at").
+		From("run").
+		Where(sq.Eq{"id": id}).
+		RunWith(s.db).QueryRow().
+		Scan(&run.ID, &run.IndexImage, &run.Flags, &run.CreatedAt)
+	if err == sql.ErrNoRows {
+		return run, fmt.Errorf("run %s not found in the state database", id)
+	}
+	if err != nil {
+		return run, fmt.Errorf("unable to get run %s : %s", id, err)
+	}
+
+	return run, nil
+}
+
+// Bundles returns the state of every bundle of the run
+func (s *Store) Bundles(runID string) ([]BundleState, error) {
+	rows, err := sq.Select("run_id", "bundle_name", "pool_name", "package_name", "bundle_image", "claim_name",
+		"cd_namespace", "job_name", "result", "reason", "message", "error", "cluster_version", "started_at",
+		"finished_at", "bundle_data").
+		From("run_bundle").
+		Where(sq.Eq{"run_id": runID}).
+		OrderBy("bundle_name", "pool_name").
+		RunWith(s.db).Query()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bundles of run %s : %s", runID, err)
+	}
+	defer rows.Close()
+
+	var bundles []BundleState
+	for rows.Next() {
+		var packageName, bundleImage, claimName, cdNamespace, jobName, result, reason, message, errMsg,
+			clusterVersion, startedAt, finishedAt, bundleData sql.NullString
+		bundle := BundleState{}
+
+		if err := rows.Scan(&bundle.RunID, &bundle.BundleName, &bundle.PoolName, &packageName, &bundleImage, &claimName,
+			&cdNamespace, &jobName, &result, &reason, &message, &errMsg, &clusterVersion, &startedAt, &finishedAt,
+			&bundleData); err != nil {
+			return nil, fmt.Errorf("unable to scan bundle of run %s : %s", runID, err)
+		}
+
+		bundle.PackageName = packageName.String
+		bundle.BundleImage = bundleImage.String
+		bundle.ClaimName = claimName.String
+		bundle.ClusterDeploymentNamespace = cdNamespace.String
+		bundle.JobName = jobName.String
+		bundle.Result = batchv1.JobConditionType(result.String)
+		bundle.Reason = reason.String
+		bundle.Message = message.String
+		bundle.Error = errMsg.String
+		bundle.ClusterVersion = clusterVersion.String
+		bundle.StartedAt = startedAt.String
+		bundle.FinishedAt = finishedAt.String
+
+		if len(bundleData.String) > 0 {
+			if err := json.Unmarshal([]byte(bundleData.String), &bundle.Bundle); err != nil {
+				return nil, fmt.Errorf("unable to read bundle %s of run %s : %s", bundle.BundleName, runID, err)
+			}
+		}
+
+		bundles = append(bundles, bundle)
+	}
+
+	return bundles, rows.Err()
+}
+
+// UpdateBundle saves the progress made on the bundle
+func (s *Store) UpdateBundle(bundle BundleState) error {
+	_, err := sq.Update("run_bundle").
+		Set("claim_name", bundle.ClaimName).
+		Set("cd_namespace", bundle.ClusterDeploymentNamespace).
+		Set("job_name", bundle.JobName).
+		Set("result", string(bundle.Result)).
+		Set("reason", bundle.Reason).
+		Set("message", bundle.Message).
+		Set("error", bundle.Error).
+		Set("cluster_version", bundle.ClusterVersion).
+		Set("started_at", bundle.StartedAt).
+		Set("finished_at", bundle.FinishedAt).
+		Set("updated_at", Now()).
+		Where(sq.Eq{"run_id": bundle.RunID, "bundle_name": bundle.BundleName, "pool_name": bundle.PoolName}).
+		RunWith(s.db).Exec()
+	if err != nil {
+		return fmt.Errorf("unable to save state of bundle %s : %s", bundle.BundleName, err)
+	}
+
+	return nil
+}
+
+// Finished reports whether the audit Job of the bundle reached a final condition
+func (b BundleState) Finished() bool {
+	return b.Result == batchv1.JobComplete || b.Result == batchv1.JobFailed
+}
+
+// Duration returns how long the audit of the bundle took, or zero when it has not finished
+func (b BundleState) Duration() time.Duration {
+	started, err := time.Parse(time.RFC3339, b.StartedAt)
+	if err != nil {
+		return 0
+	}
+
+	finished, err := time.Parse(time.RFC3339, b.FinishedAt)
+	if err != nil {
+		return 0
+	}
+
+	return finished.Sub(started)
+}
+
+// Now returns the current time in the format recorded in the state database
+func Now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
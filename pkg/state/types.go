@@ -0,0 +1,49 @@
+package state
+
+import (
+	"audit-tool-orchestrator/pkg/index"
+	"database/sql"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// Store persists the progress of the orchestration runs in a local SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Run is one execution of the orchestration over the bundles of an index image
+type Run struct {
+	ID         string `json:"id"`
+	IndexImage string `json:"image"`
+	Flags      string `json:"flags"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// BundleState records the resources created to audit one bundle of a run
+type BundleState struct {
+	RunID      string `json:"runId"`
+	BundleName string `json:"bundleName"`
+	// PoolName is the ClusterPool the bundle is audited on. The pool of the run flags when empty.
+	PoolName                   string                   `json:"poolName,omitempty"`
+	PackageName                string                   `json:"packageName"`
+	BundleImage                string                   `json:"bundleImage"`
+	ClaimName                  string                   `json:"claimName"`
+	ClusterDeploymentNamespace string                   `json:"clusterDeploymentNamespace"`
+	JobName                    string                   `json:"jobName"`
+	Result                     batchv1.JobConditionType `json:"result"`
+	Reason                     string                   `json:"reason"`
+	Message                    string                   `json:"message"`
+	Error                      string                   `json:"error"`
+	ClusterVersion             string                   `json:"clusterVersion"`
+	StartedAt                  string                   `json:"startedAt"`
+	FinishedAt                 string                   `json:"finishedAt"`
+	// Bundle is the bundle as read from the index when the run was created
+	Bundle index.Bundle `json:"bundle"`
+}
+
+// Target is a bundle of a run and the ClusterPool it is audited on. A bundle is audited once per target.
+type Target struct {
+	Bundle index.Bundle
+	// PoolName is empty when the run audits every bundle on the pool of its flags
+	PoolName string
+}
@@ -0,0 +1,38 @@
+package state
+
+const sqliteDriver = "sqlite3"
+
+// runIDSuffixLength is the number of random bytes appended to the time of a run in its identifier
+const runIDSuffixLength = 3
+
+// DefaultDBName is the file name of the state database within the user's ~/.ato directory
+const DefaultDBName = "state.db"
+
+const createRunsTable = `CREATE TABLE IF NOT EXISTS run (
+	id TEXT PRIMARY KEY,
+	index_image TEXT,
+	flags TEXT,
+	created_at TEXT
+)`
+
+const createBundlesTable = `CREATE TABLE IF NOT EXISTS run_bundle (
+	run_id TEXT NOT NULL,
+	bundle_name TEXT NOT NULL,
+	pool_name TEXT NOT NULL DEFAULT '',
+	package_name TEXT,
+	bundle_image TEXT,
+	claim_name TEXT,
+	cd_namespace TEXT,
+	job_name TEXT,
+	result TEXT,
+	reason TEXT,
+	message TEXT,
+	error TEXT,
+	cluster_version TEXT,
+	started_at TEXT,
+	finished_at TEXT,
+	bundle_data TEXT,
+	updated_at TEXT,
+	PRIMARY KEY (run_id, bundle_name, pool_name),
+	FOREIGN KEY (run_id) REFERENCES run(id)
+)`
@@ -0,0 +1,199 @@
+package suite
+
+import (
+	"audit-tool-orchestrator/pkg/artifacts"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Register adds the suite to the registry so it can be selected by name
+func Register(suite Suite) error {
+	if len(suite.Name) == 0 {
+		return fmt.Errorf("unable to register a suite without name")
+	}
+
+	if len(suite.Template) == 0 {
+		return fmt.Errorf("unable to register the suite %s without Job template", suite.Name)
+	}
+
+	if _, ok := suites[suite.Name]; ok {
+		return fmt.Errorf("the suite %s is already registered", suite.Name)
+	}
+
+	if suite.ParseResult == nil {
+		suite.ParseResult = ParseJobResult
+	}
+
+	suites[suite.Name] = suite
+
+	return nil
+}
+
+// Get returns the registered suite with the given name
+func Get(name string) (Suite, error) {
+	suite, ok := suites[name]
+	if !ok {
+		return suite, fmt.Errorf("unknown suite %s. The registered suites are %s",
+			name, strings.Join(Names(), ", "))
+	}
+
+	return suite, nil
+}
+
+// Names returns the sorted names of the registered suites
+func Names() []string {
+	var names []string
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseJobResult reports the final status of the Job as the result of the suite
+func ParseJobResult(job *batchv1.Job) orchestrate.JobStatus {
+	status, _ := orchestrate.JobFinalStatus(job)
+	return status
+}
+
+// NewJob builds the Job of the suite for the bundle. The Job is named after the flags and the suite, and
+// its deadline is the suite timeout unless the flags set one.
+func NewJob(suite Suite, flags orchestrate.JobFlags) (batchv1.Job, error) {
+	flags.Name = orchestrate.SuffixedJobName(flags.Name, suite.Name)
+	if flags.Deadline == 0 {
+		flags.Deadline = suite.Timeout
+	}
+
+	return orchestrate.RenderJob(suite.Template, flags)
+}
+
+// Run creates the Job of the suite on the cluster under test and waits for it to finish, retrying it on
+// infrastructure failures
+func Run(ctx context.Context, auditClient kubernetes.Interface, suite Suite, flags orchestrate.JobFlags) Result {
+	result := Result{Suite: suite.Name, JobName: orchestrate.SuffixedJobName(flags.Name, suite.Name)}
+	result.Result = orchestrate.AuditError
+
+	auditJob, err := NewJob(suite, flags)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	job, err := auditClient.BatchV1().Jobs(auditJob.Namespace).Create(ctx, &auditJob, metav1.CreateOptions{})
+	if err != nil {
+		result.Message = fmt.Sprintf("unable to create Job %s : %s", auditJob.Name, err)
+		return result
+	}
+
+	started := time.Now()
+	stopLogs := artifacts.Follow(ctx, auditClient, job.Namespace, job.Name, flags.FollowLogs)
+	status, err := orchestrate.AwaitAuditJob(ctx, auditClient, auditJob, job, flags.Retries)
+	stopLogs()
+	result.Duration = time.Since(started)
+
+	artifacts.Save(ctx, auditClient, job.Namespace, job.Name, flags.Artifacts())
+
+	if err != nil || status.Infrastructure {
+		result.JobStatus = status
+		return result
+	}
+
+	job, err = auditClient.BatchV1().Jobs(job.Namespace).Get(ctx, job.Name, metav1.GetOptions{})
+	if err != nil {
+		result.Message = fmt.Sprintf("unable to get Job %s : %s", auditJob.Name, err)
+		return result
+	}
+
+	result.JobStatus = suite.ParseResult(job)
+
+	return result
+}
+
+// RunAll runs the suites of the flags on the cluster under test, one after the other or all at once when
+// flags.Parallel is set, and returns the result of each suite in the order they were selected
+func RunAll(ctx context.Context, auditClient kubernetes.Interface, flags orchestrate.JobFlags) ([]Result, error) {
+	var selected []Suite
+	for _, name := range flags.Suites {
+		suite, err := Get(name)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, suite)
+	}
+
+	results := make([]Result, len(selected))
+	if !flags.Parallel {
+		for i, suite := range selected {
+			log.Infof("Running suite %s\n", suite.Name)
+			results[i] = Run(ctx, auditClient, suite, flags)
+		}
+
+		return results, nil
+	}
+
+	var wg sync.WaitGroup
+	for i, suite := range selected {
+		wg.Add(1)
+		go func(i int, suite Suite) {
+			defer wg.Done()
+			log.Infof("Running suite %s\n", suite.Name)
+			results[i] = Run(ctx, auditClient, suite, flags)
+		}(i, suite)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// PrintSummary writes a table with the result of each suite
+func PrintSummary(out io.Writer, results []Result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "SUITE\tJOB\tRESULT\tREASON\tMESSAGE")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			result.Suite, result.JobName, result.Result, result.Reason, result.Message)
+	}
+
+	return w.Flush()
+}
+
+// BucketNamespaces returns the namespaces of the Jobs of the suites of the flags whose template uses the bucket
+func BucketNamespaces(flags orchestrate.JobFlags) ([]string, error) {
+	var namespaces []string
+	seen := map[string]bool{}
+	for _, name := range flags.Suites {
+		suite, err := Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !orchestrate.JobTemplateUsesBucket(suite.Template) {
+			continue
+		}
+
+		auditJob, err := NewJob(suite, flags)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[auditJob.Namespace] {
+			seen[auditJob.Namespace] = true
+			namespaces = append(namespaces, auditJob.Namespace)
+		}
+	}
+
+	return namespaces, nil
+}
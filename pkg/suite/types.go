@@ -0,0 +1,27 @@
+package suite
+
+import (
+	"audit-tool-orchestrator/pkg/orchestrate"
+	batchv1 "k8s.io/api/batch/v1"
+	"time"
+)
+
+// Suite is an audit run as a Job on the cluster under test
+type Suite struct {
+	Name        string
+	Description string
+	// Template is the Go template of the Job in YAML, which gets the orchestrate.JobTemplateData variables
+	Template string
+	// Timeout bounds the time waited for the Job to finish. No limit when zero.
+	Timeout time.Duration
+	// ParseResult reads the outcome of the suite from the finished Job. ParseJobResult when nil.
+	ParseResult func(job *batchv1.Job) orchestrate.JobStatus
+}
+
+// Result is the outcome of running one suite against the bundle
+type Result struct {
+	Suite    string        `json:"suite"`
+	JobName  string        `json:"jobName"`
+	Duration time.Duration `json:"duration"`
+	orchestrate.JobStatus
+}
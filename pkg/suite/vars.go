@@ -0,0 +1,42 @@
+package suite
+
+import (
+	"audit-tool-orchestrator/pkg/orchestrate"
+	_ "embed"
+	"time"
+)
+
+//go:embed templates/scorecard-job.yaml
+var scorecardJobTemplate string
+
+//go:embed templates/bundle-validate-job.yaml
+var bundleValidateJobTemplate string
+
+const Capabilities = "capabilities"
+const Scorecard = "scorecard"
+const BundleValidate = "bundle-validate"
+
+// suites is the registry of the audit suites, holding the built-in ones until others are registered
+var suites = map[string]Suite{
+	Capabilities: {
+		Name:        Capabilities,
+		Description: "installs and uninstalls the bundle with the capabilities-tool",
+		Template:    orchestrate.DefaultJobTemplate,
+		Timeout:     time.Hour,
+		ParseResult: ParseJobResult,
+	},
+	Scorecard: {
+		Name:        Scorecard,
+		Description: "runs the operator-sdk scorecard tests of the bundle",
+		Template:    scorecardJobTemplate,
+		Timeout:     30 * time.Minute,
+		ParseResult: ParseJobResult,
+	},
+	BundleValidate: {
+		Name:        BundleValidate,
+		Description: "validates the bundle with operator-sdk bundle validate and the operatorframework suite",
+		Template:    bundleValidateJobTemplate,
+		Timeout:     10 * time.Minute,
+		ParseResult: ParseJobResult,
+	},
+}
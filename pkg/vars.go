@@ -1,10 +1,20 @@
 package pkg
 
 const JSON = "json"
+const YAML = "yaml"
+const CSV = "csv"
+const NDJSON = "ndjson"
 const Yes = "YES"
 const No = "NO"
 const DefaultContainerTool = Docker
 const Docker = "docker"
 const Podman = "podman"
 
+// None reads the images straight from the registry or a local OCI layout / docker save tarball
+// without a container engine
+const None = "none"
+
 const InfrastructureAnnotation = "operators.openshift.io/infrastructure-features"
+
+// TemporaryDir holds the indexes extracted from the images, see GenerateTemporaryDirs
+const TemporaryDir = "/tmp/ato"
@@ -1 +1,124 @@
 package verify
+
+import (
+	"audit-tool-orchestrator/pkg/bucket"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/orchestrate"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	batchv1 "k8s.io/api/batch/v1"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// VerifyBundles checks the audit results stored in the bucket by the run for every bundle of the list
+func VerifyBundles(ctx context.Context, client *bucket.Client, runID string, list index.BundleList) (Report, error) {
+	report := Report{}
+
+	for _, bundle := range list.Bundles {
+		verification, err := verifyBundle(ctx, client, runID, bundle)
+		if err != nil {
+			return report, err
+		}
+
+		switch verification.Status {
+		case Missing:
+			report.Missing++
+		case Failed:
+			report.Failed++
+		case Succeeded:
+			report.Succeeded++
+		}
+
+		report.Bundles = append(report.Bundles, verification)
+	}
+
+	return report, nil
+}
+
+func verifyBundle(ctx context.Context, client *bucket.Client, runID string,
+	bundle index.Bundle) (BundleVerification, error) {
+	verification := BundleVerification{
+		BundleName:  bundle.Name,
+		PackageName: bundle.PackageName,
+		Status:      Missing,
+	}
+
+	objects, err := client.List(ctx, path.Join(runID, bundle.Name)+"/")
+	if err != nil {
+		return verification, err
+	}
+
+	if len(objects) == 0 {
+		verification.Reason = "no audit result found in the bucket"
+		return verification, nil
+	}
+
+	// the bundle may have been audited on several pools, each with its own result, and fails when any did
+	found := 0
+	for _, object := range objects {
+		if path.Base(object.Key) != orchestrate.ResultObjectName {
+			continue
+		}
+		found++
+
+		data, err := client.Get(ctx, object.Key)
+		if err != nil {
+			return verification, err
+		}
+
+		result := orchestrate.BundleAuditResult{}
+		if err := json.Unmarshal(data, &result); err != nil {
+			log.Warnf("Unable to parse audit result %s: %v\n", object.Key, err)
+			verification.Object = object.Key
+			verification.Status = Failed
+			verification.Reason = fmt.Sprintf("unable to parse audit result : %s", err)
+			return verification, nil
+		}
+
+		if result.Result != batchv1.JobComplete {
+			verification.Object = object.Key
+			verification.Status = Failed
+			verification.Reason = strings.TrimSpace(fmt.Sprintf("audit finished with result %s %s %s",
+				result.Result, result.Reason, result.Error))
+			return verification, nil
+		}
+
+		if len(verification.Object) == 0 {
+			verification.Object = object.Key
+		}
+	}
+
+	if found > 0 {
+		verification.Status = Succeeded
+		return verification, nil
+	}
+
+	verification.Status = Failed
+	verification.Reason = fmt.Sprintf("%d objects found but none is a %s", len(objects), orchestrate.ResultObjectName)
+
+	return verification, nil
+}
+
+// Print writes a table with the verification of each bundle followed by the totals
+func (r Report) Print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "BUNDLE\tPACKAGE\tSTATUS\tREASON")
+	for _, bundle := range r.Bundles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", bundle.BundleName, bundle.PackageName, bundle.Status, bundle.Reason)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\n%d bundles: %d succeeded, %d failed, %d without result\n",
+		len(r.Bundles), r.Succeeded, r.Failed, r.Missing)
+
+	return err
+}
@@ -0,0 +1,76 @@
+package verify_test
+
+import (
+	"audit-tool-orchestrator/pkg/bucket/fake"
+	"audit-tool-orchestrator/pkg/index"
+	"audit-tool-orchestrator/pkg/verify"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyBundles(t *testing.T) {
+	server := fake.NewServer("audit", map[string][]byte{
+		"run-1/etcd.v0.9.4/result.json":                        []byte(`{"result":"Complete"}`),
+		"run-1/prometheus.v0.47.0/ocp-4-10-aws/result.json":    []byte(`{"result":"Complete"}`),
+		"run-1/prometheus.v0.47.0/ocp-4-11-aws/result.json":    []byte(`{"result":"Failed","reason":"BackoffLimitExceeded"}`),
+		"run-1/strimzi.v0.28.0/artifacts/ato-strimzi/job.yaml": []byte("kind: Job"),
+		"run-2/jaeger.v1.34.1/result.json":                     []byte(`{"result":"Complete"}`),
+	})
+	defer server.Close()
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := index.BundleList{Bundles: []index.Bundle{
+		{Name: "etcd.v0.9.4", PackageName: "etcd"},
+		{Name: "prometheus.v0.47.0", PackageName: "prometheus"},
+		{Name: "strimzi.v0.28.0", PackageName: "strimzi"},
+		{Name: "jaeger.v1.34.1", PackageName: "jaeger"},
+	}}
+
+	got, err := verify.VerifyBundles(context.Background(), client, "run-1", list)
+	if err != nil {
+		t.Fatalf("VerifyBundles() error = %v", err)
+	}
+
+	want := verify.Report{
+		Bundles: []verify.BundleVerification{
+			{
+				BundleName:  "etcd.v0.9.4",
+				PackageName: "etcd",
+				Status:      verify.Succeeded,
+				Object:      "run-1/etcd.v0.9.4/result.json",
+			},
+			{
+				BundleName:  "prometheus.v0.47.0",
+				PackageName: "prometheus",
+				Status:      verify.Failed,
+				Object:      "run-1/prometheus.v0.47.0/ocp-4-11-aws/result.json",
+				Reason:      "audit finished with result Failed BackoffLimitExceeded",
+			},
+			{
+				BundleName:  "strimzi.v0.28.0",
+				PackageName: "strimzi",
+				Status:      verify.Failed,
+				Reason:      "1 objects found but none is a result.json",
+			},
+			{
+				// the result of another run does not count
+				BundleName:  "jaeger.v1.34.1",
+				PackageName: "jaeger",
+				Status:      verify.Missing,
+				Reason:      "no audit result found in the bucket",
+			},
+		},
+		Missing:   1,
+		Failed:    2,
+		Succeeded: 1,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyBundles() = %+v, want %+v", got, want)
+	}
+}
@@ -1 +1,29 @@
 package verify
+
+import "audit-tool-orchestrator/pkg/bucket"
+
+type VerifyFlags struct {
+	RunID          string `json:"runId"`
+	BundleList     string `json:"bundleList"`
+	BucketName     string `json:"bucket-name"`
+	Secure         bool   `json:"secure"`
+	RequireSuccess bool   `json:"requireSuccess"`
+	// BucketCredentials tell where the credentials of the bucket are read from
+	BucketCredentials bucket.CredentialsFlags `json:"bucketCredentials"`
+}
+
+// BundleVerification is the state of the audit results found in the bucket for one bundle
+type BundleVerification struct {
+	BundleName  string `json:"bundleName"`
+	PackageName string `json:"packageName"`
+	Status      string `json:"status"`
+	Object      string `json:"object,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+type Report struct {
+	Bundles   []BundleVerification `json:"bundles"`
+	Missing   int                  `json:"missing"`
+	Failed    int                  `json:"failed"`
+	Succeeded int                  `json:"succeeded"`
+}
@@ -1 +1,5 @@
 package verify
+
+const Missing = "Missing"
+const Failed = "Failed"
+const Succeeded = "Succeeded"